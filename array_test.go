@@ -0,0 +1,148 @@
+package conditions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrayEval(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("a", &Integer{Value: 1})
+	env.Set("b", &Integer{Value: 2})
+	env.Set("x", &Integer{Value: 2})
+	env.Set("price", &Integer{Value: 2})
+	env.Set("matrix", &Array{Elements: []Expression{NewIntegerArray(1, 2), NewIntegerArray()}, ElemType: ARRAY_INTEGER_OBJ})
+	for input, expect := range map[string]Object{
+		`x in [a, b]`:                          boolTrue,
+		`x in [a, a + 2]`:                      boolFalse,
+		`x in []`:                              boolFalse,
+		`len([]) == 0 && len([[1], [2]]) == 2`: boolTrue,
+		`true in [false, 1 > 0]`:               boolTrue,
+		`price in [1.5, 2]`:                    boolTrue,
+		`1.5 + 1 > 2 && 0.5 < 1`:               boolTrue,
+		`[1, 2] in [[1, 2], [3]]`:              boolTrue,
+		`[2, 1] in [[1, 2], [3]]`:              boolFalse,
+		`any(matrix, row, len(row) == 0)`:      boolTrue,
+		`count(matrix, row, 2 in row)`:         &Integer{Value: 1},
+		`[1, 2,] in [[1, 2]]`:                  boolTrue,
+	} {
+		program, err := Compile(input)
+		if assert.Nil(t, err, input) {
+			assert.Equal(t, expect, Eval(program, env), input)
+		}
+	}
+	assert.Equal(t, "[[1,2],[]]", env.store["matrix"].(*Array).String())
+	assert.Equal(t, "2.0", (&Float{Value: 2}).String())
+}
+
+func TestArrayTypeCheck(t *testing.T) {
+	schema := Schema{"age": INTEGER_OBJ, "tags": ARRAY_STRING_OBJ, "price": FLOAT_OBJ, "matrix": ArrayOf(ARRAY_INTEGER_OBJ)}
+	for input, expect := range map[string]string{
+		`age in [1, "a"]`:     "Array elements must be of the same type, got INTEGER and STRING",
+		`age in ["a"]`:        "InfixExpression <exp>in<exp> right expect ARRAY_INTEGER_OBJ, got ARRAY_STRING_OBJ",
+		`age in age`:          "InfixExpression <exp>in<exp> right expect ARRAY_INTEGER_OBJ, got INTEGER",
+		`[[1], ["a"]] == age`: "Array elements must be of the same type, got ARRAY_INTEGER_OBJ and ARRAY_STRING_OBJ",
+		`len(age)`:            "CallExpression len args type error",
+		`price > "1"`:         "InfixExpression <exp>><exp> right expect FLOAT, got STRING",
+		`[1] in matrix && "a" in tags && tags in [1]`: "right expect ARRAY_ARRAY_STRING_OBJ, got ARRAY_INTEGER_OBJ",
+	} {
+		p := NewParser(NewLexer(input))
+		p.SetSchema(schema)
+		p.ParseProgram()
+		if assert.NotEmpty(t, p.Errors(), input) {
+			assert.Contains(t, p.Errors()[0], expect, input)
+		}
+	}
+	for input, expect := range map[string]ObjectType{
+		`[]`:                ARRAY_OBJ,
+		`[1, 2.5]`:          ArrayOf(FLOAT_OBJ),
+		`[[1], []]`:         ArrayOf(ARRAY_INTEGER_OBJ),
+		`[age, 1]`:          ARRAY_INTEGER_OBJ,
+		`[unknown, "a"]`:    ARRAY_STRING_OBJ,
+		`price > age + 0.5`: BOOLEAN_OBJ,
+		`[1] in matrix && "a" in tags && age in [] && price in [1, 2]`: BOOLEAN_OBJ,
+		`cidr_match(ip("10.0.0.1"), [])`:                               BOOLEAN_OBJ,
+	} {
+		p := NewParser(NewLexer(input))
+		p.SetSchema(schema)
+		program := p.ParseProgram()
+		if assert.Empty(t, p.Errors(), input) {
+			assert.Equal(t, expect, p.CheckType(program), input)
+		}
+	}
+
+	// 显式允许元素类型不同的数组
+	p := NewParser(NewLexer(`"a" in [1, "a", true]`))
+	p.AllowMixedArrays()
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+	assert.Equal(t, boolTrue, Eval(program, NewEnvironment()))
+
+	assert.Equal(t, INTEGER_OBJ, mustElementType(t, ARRAY_INTEGER_OBJ))
+	assert.Equal(t, ARRAY_STRING_OBJ, mustElementType(t, ArrayOf(ARRAY_STRING_OBJ)))
+	assert.Equal(t, IDENT_OBJ, mustElementType(t, ARRAY_OBJ))
+	assert.Equal(t, ARRAY_OBJ, mustElementType(t, ArrayOf(ARRAY_OBJ)))
+	_, ok := ElementType(STRING_OBJ)
+	assert.False(t, ok)
+}
+
+func mustElementType(t *testing.T, typ ObjectType) ObjectType {
+	elem, ok := ElementType(typ)
+	assert.True(t, ok, typ)
+	return elem
+}
+
+func TestArrayJSONAndBind(t *testing.T) {
+	program, err := Compile(`x in [a, 1.5, 2] || [[true], []] == y || z in []`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	data, err := json.Marshal(program)
+	assert.Nil(t, err)
+	loaded, err := ParseJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, program.String(), loaded.String())
+	assert.Equal(t, `x in [a, 1.5, 2] || [[true], []] == y || z in []`, Format(loaded))
+
+	// 版本1的数组
+	loaded, err = ParseJSON([]byte(`{"version":1,"expression":{"kind":"infix","operator":"in",
+		"left":{"kind":"identifier","value":"x"},"right":{"kind":"array_string","value":["a","b"]}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, NewStringArray("a", "b"), loaded.Expression.(*InfixExpression).Right)
+
+	schema, err := ParseSchema([]byte(`{"a": "[]float", "b": "[][]int", "c": "[]any", "d": "[]ip"}`))
+	assert.EqualError(t, err, `identifier d: unknown type "[]ip"`)
+	schema, err = ParseSchema([]byte(`{"a": "[]float", "b": "[][]int", "c": "[]any"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, Schema{"a": ArrayOf(FLOAT_OBJ), "b": ArrayOf(ARRAY_INTEGER_OBJ), "c": ARRAY_OBJ}, schema)
+
+	env := NewEnvironment()
+	assert.Nil(t, Bind(env, map[string]interface{}{
+		"flags":  []bool{true},
+		"prices": []float64{1.5, 2},
+		"groups": [][]string{{"a"}, {}},
+		"mixed":  []interface{}{1.0, 2.5},
+	}))
+	for name, expect := range map[string]string{
+		"flags":  "[true]",
+		"prices": "[1.5,2.0]",
+		"groups": `[["a"],[]]`,
+		"mixed":  "[1,2.5]",
+	} {
+		obj, _ := env.Get(name)
+		assert.Equal(t, expect, obj.(*Array).String(), name)
+	}
+	obj, _ := env.Get("groups")
+	assert.Equal(t, ArrayOf(ARRAY_STRING_OBJ), obj.ObjectType())
+	obj, _ = env.Get("prices")
+	assert.Equal(t, ArrayOf(FLOAT_OBJ), obj.ObjectType())
+	_, err = ToObject([]interface{}{1, "a"})
+	assert.NotNil(t, err)
+
+	where, args, err := ToSQL(mustCompile(t, `age in [] || price in [1.5, cap]`), MySQL)
+	assert.Nil(t, err)
+	assert.Equal(t, "(1 = 0 OR `price` IN (?, `cap`))", where)
+	assert.Equal(t, []interface{}{1.5}, args)
+}
@@ -3,7 +3,9 @@ package conditions
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ObjectType string
@@ -12,8 +14,13 @@ const (
 	INTEGER_OBJ       ObjectType = "INTEGER"
 	STRING_OBJ        ObjectType = "STRING"
 	BOOLEAN_OBJ       ObjectType = "BOLLEAN"
+	FLOAT_OBJ         ObjectType = "FLOAT"
+	ARRAY_OBJ         ObjectType = "ARRAY_OBJ" // 元素类型未知的数组
 	ARRAY_INTEGER_OBJ ObjectType = "ARRAY_INTEGER_OBJ"
 	ARRAY_STRING_OBJ  ObjectType = "ARRAY_STRING_OBJ"
+	TIME_OBJ          ObjectType = "TIME"
+	DURATION_OBJ      ObjectType = "DURATION"
+	IP_OBJ            ObjectType = "IP"
 	FUNCTION_OBJ      ObjectType = "FUNCTION_OBJ"
 	BUILTIN_OBJ       ObjectType = "BUILTIN_OBJ"
 	NULL_OBJ          ObjectType = "NULL"
@@ -46,7 +53,9 @@ type Program struct {
 func (p *Program) node() {}
 func (p *Program) String() string {
 	var out bytes.Buffer
-	out.WriteString(p.Expression.String())
+	if p.Expression != nil {
+		out.WriteString(p.Expression.String())
+	}
 	return out.String()
 }
 
@@ -66,6 +75,7 @@ type Error struct {
 }
 
 func (e *Error) ObjectType() ObjectType { return ERROR_OBJ }
+func (e *Error) Error() string          { return e.Message }
 
 // Integer 整形字面量, 123 456
 type Integer struct {
@@ -77,6 +87,24 @@ func (il *Integer) expressionNode()        {}
 func (il *Integer) ObjectType() ObjectType { return INTEGER_OBJ }
 func (il *Integer) String() string         { return fmt.Sprintf("%v", il.Value) }
 
+// Float 浮点数字面量, 1.5 0.25
+type Float struct {
+	Value float64
+}
+
+func (f *Float) node()                  {}
+func (f *Float) expressionNode()        {}
+func (f *Float) ObjectType() ObjectType { return FLOAT_OBJ }
+
+// String 总是带有小数点，保证可以重新解析为浮点数
+func (f *Float) String() string {
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
 // Boolean bool字面量, true false
 type Boolean struct {
 	Value bool
@@ -97,47 +125,124 @@ func (s *String) expressionNode()        {}
 func (s *String) ObjectType() ObjectType { return STRING_OBJ }
 func (s *String) String() string         { return fmt.Sprintf("\"%s\"", s.Value) }
 
-type ArrayInteger struct {
-	Value []int64
+// Time 时间字面量, @2024-01-01T00:00:00Z
+type Time struct {
+	Value time.Time
 }
 
-func (a *ArrayInteger) node()                  {}
-func (a *ArrayInteger) expressionNode()        {}
-func (a *ArrayInteger) ObjectType() ObjectType { return ARRAY_INTEGER_OBJ }
-func (a *ArrayInteger) String() string {
-	var out bytes.Buffer
-	out.WriteString("[")
-	for _, item := range a.Value {
-		out.WriteString(fmt.Sprintf("%d", item))
-		out.WriteString(",")
+func (t *Time) node()                  {}
+func (t *Time) expressionNode()        {}
+func (t *Time) ObjectType() ObjectType { return TIME_OBJ }
+func (t *Time) String() string         { return "@" + t.Value.Format(time.RFC3339Nano) }
+
+// Duration 时间间隔字面量, 7d 15m 1h30m
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) node()                  {}
+func (d *Duration) expressionNode()        {}
+func (d *Duration) ObjectType() ObjectType { return DURATION_OBJ }
+func (d *Duration) String() string         { return formatDuration(d.Value) }
+
+// Array 数组, [1, 2, 3] ["a", b] [[1], [2, 3]] []
+// 字面量的元素可以是任意表达式，求值后的元素都是字面量
+type Array struct {
+	Elements []Expression
+	ElemType ObjectType // 元素类型，空数组、元素类型不同或者元素中有未求值的表达式时为IDENT_OBJ
+}
+
+// NewArray 根据元素推断数组的元素类型
+func NewArray(elems ...Expression) *Array {
+	return &Array{Elements: elems, ElemType: commonType(elems)}
+}
+
+// NewIntegerArray 整数数组
+func NewIntegerArray(vals ...int64) *Array {
+	arr := &Array{Elements: make([]Expression, 0, len(vals)), ElemType: INTEGER_OBJ}
+	for _, v := range vals {
+		arr.Elements = append(arr.Elements, &Integer{Value: v})
 	}
-	s := out.String()
-	if s[len(s)-1] == ',' {
-		s = s[0 : len(s)-1]
+	return arr
+}
+
+// NewStringArray 字符串数组
+func NewStringArray(vals ...string) *Array {
+	arr := &Array{Elements: make([]Expression, 0, len(vals)), ElemType: STRING_OBJ}
+	for _, v := range vals {
+		arr.Elements = append(arr.Elements, &String{Value: v})
 	}
-	return s + "]"
+	return arr
 }
 
-// ArrayString ["a", "b", "c"]
-type ArrayString struct {
-	Value []string
+func (a *Array) node()                  {}
+func (a *Array) expressionNode()        {}
+func (a *Array) ObjectType() ObjectType { return ArrayOf(a.ElemType) }
+func (a *Array) String() string {
+	items := make([]string, 0, len(a.Elements))
+	for _, elem := range a.Elements {
+		items = append(items, elem.String())
+	}
+	return "[" + strings.Join(items, ",") + "]"
 }
 
-func (a *ArrayString) node()                  {}
-func (a *ArrayString) expressionNode()        {}
-func (a *ArrayString) ObjectType() ObjectType { return ARRAY_STRING_OBJ }
-func (a *ArrayString) String() string {
-	var out bytes.Buffer
-	out.WriteString("[")
-	for _, item := range a.Value {
-		out.WriteString(item)
-		out.WriteString(",")
+// Strings 返回字符串数组的元素，有其它类型的元素时返回false
+func (a *Array) Strings() ([]string, bool) {
+	ret := make([]string, 0, len(a.Elements))
+	for _, elem := range a.Elements {
+		s, ok := elem.(*String)
+		if !ok {
+			return nil, false
+		}
+		ret = append(ret, s.Value)
+	}
+	return ret, true
+}
+
+// commonType 元素都是同一类型的字面量时返回该类型，整数和浮点数混合时为浮点数，否则返回IDENT_OBJ
+func commonType(elems []Expression) ObjectType {
+	t := IDENT_OBJ
+	for _, elem := range elems {
+		obj, ok := elem.(Object)
+		if !ok || !isLiteral(elem) {
+			return IDENT_OBJ
+		}
+		if t, ok = unifyTypes(t, obj.ObjectType()); !ok {
+			return IDENT_OBJ
+		}
+	}
+	return t
+}
+
+// ArrayOf 元素类型为elem的数组类型，例如 ArrayOf(INTEGER_OBJ) == ARRAY_INTEGER_OBJ，
+// ArrayOf(ARRAY_INTEGER_OBJ) == "ARRAY_ARRAY_INTEGER_OBJ"，元素类型未知时为ARRAY_OBJ
+func ArrayOf(elem ObjectType) ObjectType {
+	switch {
+	case elem == IDENT_OBJ:
+		return ARRAY_OBJ
+	case isArrayType(elem):
+		return "ARRAY_" + ObjectType(strings.TrimSuffix(string(elem), "_OBJ")) + "_OBJ"
+	}
+	return "ARRAY_" + elem + "_OBJ"
+}
+
+// ElementType 返回数组类型的元素类型，ARRAY_OBJ的元素类型为IDENT_OBJ，不是数组时返回false
+func ElementType(t ObjectType) (ObjectType, bool) {
+	if t == ARRAY_OBJ {
+		return IDENT_OBJ, true
+	}
+	if !isArrayType(t) {
+		return "", false
 	}
-	s := out.String()
-	if s[len(s)-1] == ',' {
-		s = s[0 : len(s)-1]
+	inner := strings.TrimSuffix(strings.TrimPrefix(string(t), "ARRAY_"), "_OBJ")
+	if inner == "ARRAY" || strings.HasPrefix(inner, "ARRAY_") {
+		return ObjectType(inner + "_OBJ"), true
 	}
-	return s + "]"
+	return ObjectType(inner), true
+}
+
+func isArrayType(t ObjectType) bool {
+	return t == ARRAY_OBJ || (strings.HasPrefix(string(t), "ARRAY_") && strings.HasSuffix(string(t), "_OBJ"))
 }
 
 // CallExpression 函数调用
@@ -0,0 +1,325 @@
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ToObject 将go的原生值转换为Object
+// 支持整数、浮点数、字符串、bool、time.Time、time.Duration、net.IP以及这些类型的切片，
+// v和[]interface{}、map[string]interface{}中整数值的float64(encoding/json解析的数字)转换为整数，
+// Object会被直接返回
+func ToObject(v interface{}) (Object, error) {
+	if obj, ok := v.(Object); ok {
+		return obj, nil
+	}
+	return valueToObject(reflect.ValueOf(&v).Elem())
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	ipType       = reflect.TypeOf(net.IP{})
+)
+
+func valueToObject(v reflect.Value) (Object, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("unsupported value nil")
+	}
+	if v.CanInterface() {
+		if obj, ok := v.Interface().(Object); ok {
+			return obj, nil
+		}
+	}
+	switch v.Type() {
+	case timeType:
+		return &Time{Value: v.Interface().(time.Time)}, nil
+	case durationType:
+		return &Duration{Value: time.Duration(v.Int())}, nil
+	case ipType:
+		if v.Len() != net.IPv4len && v.Len() != net.IPv6len {
+			return nil, fmt.Errorf("invalid ip address %v", v.Interface())
+		}
+		ip := net.IP(v.Bytes())
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		return &IP{Value: ip}, nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("unsupported number %d: overflows int64", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			return nil, fmt.Errorf("unsupported number %v", v.Float())
+		}
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Bool:
+		return nativeBoolToBooleanObject(v.Bool()), nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, fmt.Errorf("unsupported value nil")
+		}
+		// encoding/json将数字解析为float64，interface{}中整数值的float64转换为整数；
+		// 声明为浮点数的字段保持浮点数，与typeToObjectType一致
+		if v.Kind() == reflect.Interface && v.Elem().Kind() == reflect.Float64 {
+			// float64(math.MaxInt64)是2^63，超出int64的范围
+			if f := v.Elem().Float(); f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+				return &Integer{Value: int64(f)}, nil
+			}
+		}
+		return valueToObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Interface {
+			return interfaceSliceToObject(v)
+		}
+		t, ok := typeToObjectType(v.Type())
+		if !ok {
+			break
+		}
+		elemType, _ := ElementType(t)
+		arr := &Array{Elements: make([]Expression, 0, v.Len()), ElemType: elemType}
+		for i := 0; i < v.Len(); i++ {
+			item, err := valueToObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			// 浮点数数组中整数值的元素也使用浮点数
+			if i, ok := item.(*Integer); ok && elemType == FLOAT_OBJ {
+				item = &Float{Value: float64(i.Value)}
+			}
+			arr.Elements = append(arr.Elements, item.(Expression))
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// interfaceSliceToObject 转换[]interface{}，所有元素必须是同一种类型，整数和浮点数可以混合
+func interfaceSliceToObject(v reflect.Value) (Object, error) {
+	elems := make([]Expression, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item, err := valueToObject(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		elem, ok := item.(Expression)
+		if !ok {
+			return nil, fmt.Errorf("unsupported array element type %s", item.ObjectType())
+		}
+		elems = append(elems, elem)
+	}
+	arr := NewArray(elems...)
+	if len(elems) != 0 && arr.ElemType == IDENT_OBJ {
+		return nil, fmt.Errorf("array elements must be of the same type")
+	}
+	return arr, nil
+}
+
+// Bind 将结构体的导出字段或map[string]T的元素绑定到env中
+// 嵌套结构体的字段绑定为 Address.City，嵌入的结构体字段直接提升，
+// 结构体切片中的字段展开为数组 Items.Qty，不支持的字段类型会被忽略
+func Bind(env *Environment, record interface{}) error {
+	return bindValue(env, reflect.ValueOf(record))
+}
+
+func bindValue(env *Environment, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fmt.Errorf("cannot bind nil record")
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		flattenStruct(v, "", nil, func(name string, obj Object) {
+			env.Set(name, obj)
+		})
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("cannot bind map with key type %s", v.Type().Key())
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			obj, err := valueToObject(iter.Value())
+			if err != nil {
+				continue
+			}
+			env.Set(iter.Key().String(), obj)
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot bind %s, want struct or map", v.Type())
+}
+
+// 缓存结构体导出字段的下标
+var structFieldsCache sync.Map // map[reflect.Type][]int
+
+func structFields(t reflect.Type) []int {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]int)
+	}
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		// 嵌入的未导出结构体中的导出字段仍然可以访问
+		if f := t.Field(i); f.PkgPath == "" || (f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			fields = append(fields, i)
+		}
+	}
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// structElem 返回结构体或结构体指针的结构体类型，time.Time等可以直接转换的类型除外
+func structElem(t reflect.Type) (reflect.Type, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := typeToObjectType(t); ok || t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	return t, true
+}
+
+// onPath 递归的结构体类型只展开一层
+func onPath(path []reflect.Type, t reflect.Type) bool {
+	for _, p := range path {
+		if p == t {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenSchema 生成结构体展开后的schema，与flattenStruct的规则一致
+func flattenSchema(t reflect.Type, prefix string, path []reflect.Type, schema Schema) {
+	path = append(path, t)
+	for _, i := range structFields(t) {
+		f := t.Field(i)
+		name := prefix + f.Name + "."
+		if f.Anonymous {
+			name = prefix
+		}
+		if typ, ok := typeToObjectType(f.Type); ok {
+			schema[prefix+f.Name] = typ
+		} else if st, ok := structElem(f.Type); ok && !onPath(path, st) {
+			flattenSchema(st, name, path, schema)
+		} else if st, ok := sliceStructElem(f.Type); ok && !onPath(path, st) {
+			elems := Schema{}
+			flattenSchema(st, "", path, elems)
+			for elem, typ := range elems {
+				if typ != IP_OBJ {
+					schema[prefix+f.Name+"."+elem] = ArrayOf(typ)
+				}
+			}
+		}
+	}
+}
+
+// flattenStruct 展开结构体的字段，nil指针和不支持的字段会被忽略
+func flattenStruct(v reflect.Value, prefix string, path []reflect.Type, set func(string, Object)) {
+	path = append(path, v.Type())
+	for _, i := range structFields(v.Type()) {
+		f := v.Type().Field(i)
+		fv := v.Field(i)
+		name := prefix + f.Name + "."
+		if f.Anonymous {
+			name = prefix
+		}
+		if _, ok := typeToObjectType(f.Type); ok {
+			if obj, err := valueToObject(fv); err == nil {
+				set(prefix+f.Name, obj)
+			}
+		} else if st, ok := structElem(f.Type); ok && !onPath(path, st) {
+			if fv = indirect(fv); fv.IsValid() {
+				flattenStruct(fv, name, path, set)
+			}
+		} else if st, ok := sliceStructElem(f.Type); ok && !onPath(path, st) {
+			flattenSlice(fv, st, prefix+f.Name+".", path, set)
+		}
+	}
+}
+
+// flattenSlice 将结构体切片按字段展开为数组，空切片展开为空数组
+// nil元素被忽略，元素中无法绑定的字段(nil指针等)使用零值，保证各个数组按下标对齐
+func flattenSlice(v reflect.Value, elem reflect.Type, prefix string, path []reflect.Type, set func(string, Object)) {
+	elems := Schema{}
+	flattenSchema(elem, "", path, elems)
+	columns := map[string]*Array{}
+	for name, typ := range elems {
+		if typ != IP_OBJ {
+			columns[name] = &Array{Elements: []Expression{}, ElemType: typ}
+		}
+	}
+	for i := 0; i < v.Len(); i++ {
+		item := indirect(v.Index(i))
+		if !item.IsValid() {
+			continue
+		}
+		row := map[string]Object{}
+		flattenStruct(item, "", path, func(name string, obj Object) {
+			row[name] = obj
+		})
+		for name, col := range columns {
+			obj, ok := row[name]
+			if !ok {
+				obj = zeroObject(col.ElemType)
+			}
+			col.Elements = append(col.Elements, obj.(Expression))
+		}
+	}
+	for name, col := range columns {
+		set(prefix+name, col)
+	}
+}
+
+// sliceStructElem 返回结构体切片的元素类型
+func sliceStructElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil, false
+	}
+	return structElem(t.Elem())
+}
+
+// indirect 解引用指针，nil指针返回无效的Value
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// zeroObject 返回类型的零值
+func zeroObject(t ObjectType) Object {
+	switch t {
+	case INTEGER_OBJ:
+		return &Integer{}
+	case FLOAT_OBJ:
+		return &Float{}
+	case STRING_OBJ:
+		return &String{}
+	case BOOLEAN_OBJ:
+		return boolFalse
+	case TIME_OBJ:
+		return &Time{}
+	case DURATION_OBJ:
+		return &Duration{}
+	}
+	elem, _ := ElementType(t)
+	return &Array{Elements: []Expression{}, ElemType: elem}
+}
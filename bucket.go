@@ -0,0 +1,79 @@
+package conditions
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// DefaultBuckets bucket()默认的桶数，结果为0-99，可以直接和百分比比较
+const DefaultBuckets = 100
+
+// Bucket 计算key在salt下的桶号，结果在[0, buckets)之间，buckets<=0时按1处理，结果为0
+//
+// 算法为 FNV-1a 64位哈希 fnv1a64(salt + ":" + key) % buckets，
+// 其它语言实现相同的算法可以得到相同的结果，例如python:
+//
+//	h = 0xcbf29ce484222325
+//	for b in (salt + ":" + key).encode():
+//	    h = ((h ^ b) * 0x100000001b3) % 2**64
+//	h % buckets
+func Bucket(key, salt string, buckets int64) int64 {
+	if buckets <= 0 {
+		buckets = 1
+	}
+	h := fnv.New64a()
+	h.Write([]byte(salt + ":" + key))
+	return int64(h.Sum64() % uint64(buckets))
+}
+
+// bucketKey 整数key按照十进制字符串处理
+func bucketKey(arg Object) (string, bool) {
+	switch arg := arg.(type) {
+	case *String:
+		return arg.Value, true
+	case *Integer:
+		return strconv.FormatInt(arg.Value, 10), true
+	}
+	return "", false
+}
+
+// checkBucketLiterals 类型检测时校验常量形式的桶数
+func checkBucketLiterals(name string, args []Expression) error {
+	if len(args) != 3 {
+		return nil
+	}
+	if n, ok := args[2].(*Integer); ok && n.Value <= 0 {
+		return fmt.Errorf("number of buckets must be positive, got %d", n.Value)
+	}
+	return nil
+}
+
+func init() {
+	// bucket(key, salt) 返回0-99，bucket(key, salt, 10000) 返回0-9999
+	RegisterBuiltin("bucket", func(args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of argument. got=%d, want=2 or 3", len(args))
+		}
+		key, ok := bucketKey(args[0])
+		if !ok {
+			return newError("first argument to `bucket` not supported, got %s", args[0].ObjectType())
+		}
+		salt, ok := args[1].(*String)
+		if !ok {
+			return newError("second argument to `bucket` not supported, got %s", args[1].ObjectType())
+		}
+		buckets := int64(DefaultBuckets)
+		if len(args) == 3 {
+			n, ok := args[2].(*Integer)
+			if !ok {
+				return newError("third argument to `bucket` not supported, got %s", args[2].ObjectType())
+			}
+			if n.Value <= 0 {
+				return newError("number of buckets must be positive, got %d", n.Value)
+			}
+			buckets = n.Value
+		}
+		return &Integer{Value: Bucket(key, salt.Value, buckets)}
+	})
+}
@@ -0,0 +1,60 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 期望值由文档中的python实现计算，其它语言的实现需要得到相同的结果
+func TestBucketPinned(t *testing.T) {
+	tests := []struct {
+		key, salt string
+		buckets   int64
+		expect    int64
+	}{
+		{"user-1", "new_checkout", 100, 65},
+		{"user-2", "new_checkout", 100, 32},
+		{"user-1", "dark_mode", 100, 36},
+		{"42", "new_checkout", 100, 58},
+		{"user-1", "new_checkout", 10000, 1365},
+		{"", "", 100, 89},
+		{"用户", "new_checkout", 10000, 7644},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, Bucket(tt.key, tt.salt, tt.buckets), "%s %s", tt.key, tt.salt)
+	}
+	assert.Equal(t, int64(0), Bucket("user-1", "new_checkout", 0))
+	assert.Equal(t, int64(0), Bucket("user-1", "new_checkout", -10))
+
+	env := NewEnvironment()
+	env.Set("user_id", &Integer{Value: 42})
+	env.Set("name", &String{Value: "user-1"})
+	for input, expect := range map[string]Object{
+		`bucket(user_id, "new_checkout")`:       &Integer{Value: 58},
+		`bucket(name, "new_checkout") < 20`:     boolFalse,
+		`bucket(name, "new_checkout", 10000)`:   &Integer{Value: 1365},
+		`bucket(name, "dark_mode") in [36, 37]`: boolTrue,
+	} {
+		program, err := Compile(input)
+		if assert.Nil(t, err, input) {
+			assert.Equal(t, expect, Eval(program, env), input)
+		}
+	}
+	_, err := Compile(`bucket(name, "x", 0) < 1`)
+	assert.NotNil(t, err)
+	_, err = Compile(`bucket(name, 1) < 1`)
+	assert.NotNil(t, err)
+}
+
+func TestBucketDistribution(t *testing.T) {
+	counts := make([]int, DefaultBuckets)
+	const n = 100000
+	for i := 0; i < n; i++ {
+		counts[Bucket(string(rune('a'+i%26))+string(rune(i)), "rollout", DefaultBuckets)]++
+	}
+	for b, c := range counts {
+		// 每个桶期望1000个，允许20%的偏差
+		assert.InDelta(t, n/DefaultBuckets, c, n/DefaultBuckets/5, "bucket %d", b)
+	}
+}
@@ -1,5 +1,11 @@
 package conditions
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
 	Fn BuiltinFunction
@@ -17,6 +23,35 @@ func RegisterBuiltin(name string, fun BuiltinFunction) {
 	builtins[name] = &Builtin{Fn: fun}
 }
 
+// checkRegexpLiterals 类型检测时校验常量形式的正则表达式
+func checkRegexpLiterals(name string, args []Expression) error {
+	if len(args) != 2 {
+		return nil
+	}
+	if pattern, ok := args[1].(*String); ok {
+		if _, err := regexp.Compile(pattern.Value); err != nil {
+			return fmt.Errorf("invalid regexp %q: %s", pattern.Value, err)
+		}
+	}
+	return nil
+}
+
+// stringPredicate 两个字符串参数、返回bool的内置函数
+func stringPredicate(name string, fn func(s, sub string) bool, args []Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of argument. got=%d, want=2", len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("first argument to `%s` not supported, got %s", name, args[0].ObjectType())
+	}
+	sub, ok := args[1].(*String)
+	if !ok {
+		return newError("second argument to `%s` not supported, got %s", name, args[1].ObjectType())
+	}
+	return nativeBoolToBooleanObject(fn(str.Value, sub.Value))
+}
+
 func init() {
 	RegisterBuiltin("len", func(args ...Object) Object {
 		if len(args) != 1 {
@@ -25,12 +60,34 @@ func init() {
 		switch arg := args[0].(type) {
 		case *String:
 			return &Integer{Value: int64(len(arg.Value))}
-		case *ArrayString:
-			return &Integer{Value: int64(len(arg.Value))}
-		case *ArrayInteger:
-			return &Integer{Value: int64(len(arg.Value))}
+		case *Array:
+			return &Integer{Value: int64(len(arg.Elements))}
 		default:
 			return newError("argument to `len` not supported, got %s", args[0].ObjectType())
 		}
 	})
+	RegisterBuiltin("regexp", func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of argument. got=%d, want=2", len(args))
+		}
+		str, ok := args[0].(*String)
+		if !ok {
+			return newError("first argument to `regexp` not supported, got %s", args[0].ObjectType())
+		}
+		pattern, ok := args[1].(*String)
+		if !ok {
+			return newError("second argument to `regexp` not supported, got %s", args[1].ObjectType())
+		}
+		matched, err := regexp.MatchString(pattern.Value, str.Value)
+		if err != nil {
+			return newError("invalid regexp %q: %s", pattern.Value, err)
+		}
+		return nativeBoolToBooleanObject(matched)
+	})
+	RegisterBuiltin("startsWith", func(args ...Object) Object {
+		return stringPredicate("startsWith", strings.HasPrefix, args)
+	})
+	RegisterBuiltin("endsWith", func(args ...Object) Object {
+		return stringPredicate("endsWith", strings.HasSuffix, args)
+	})
 }
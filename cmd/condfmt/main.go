@@ -0,0 +1,94 @@
+// condfmt 格式化条件表达式文件，每个文件包含一个表达式
+//
+//	condfmt [-w] [-l] [-width n] [path ...]
+//
+// 不指定path时从标准输入读取，格式化结果输出到标准输出
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bdjimmy/conditions"
+)
+
+// options 命令行参数
+type options struct {
+	write bool
+	list  bool
+	width int
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 执行condfmt，返回退出码，有文件处理失败时为2
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("condfmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts options
+	fs.BoolVar(&opts.write, "w", false, "write result to (source) file instead of stdout")
+	fs.BoolVar(&opts.list, "l", false, "list files whose formatting differs from condfmt's")
+	fs.IntVar(&opts.width, "width", conditions.DefaultFormatWidth, "maximum line width, 0 disables line breaking")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	exitCode := 0
+	if fs.NArg() == 0 {
+		if err := processFile("<stdin>", stdin, false, opts, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			exitCode = 2
+		}
+		return exitCode
+	}
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			exitCode = 2
+			continue
+		}
+		err = processFile(path, f, true, opts, stdout)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			exitCode = 2
+		}
+	}
+	return exitCode
+}
+
+// processFile 格式化一个文件，writable为false时(标准输入)忽略-w
+func processFile(path string, in io.Reader, writable bool, opts options, stdout io.Writer) error {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	p := conditions.NewParser(conditions.NewLexer(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return fmt.Errorf("%s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+	res := []byte(conditions.FormatWidth(program, opts.width) + "\n")
+	if !opts.list && !opts.write {
+		_, err = stdout.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if opts.list {
+		fmt.Fprintln(stdout, path)
+	}
+	if opts.write && writable {
+		return ioutil.WriteFile(path, res, 0644)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStdin(t *testing.T) {
+	tests := []struct {
+		args   []string
+		input  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{nil, `a>1&&(b||c)`, 0, "a > 1 && (b || c)\n", ""},
+		{nil, `x in [ 0 - 5, -1.5 ]`, 0, "x in [0 - 5, -1.5]\n", ""},
+		{nil, `name == "say \"hi\"" && p ~= "^\d+$"`, 0, "name == \"say \\\"hi\\\"\" && p ~= \"^\\d+$\"\n", ""},
+		{[]string{"-width", "10"}, `a > 1 && b > 2`, 0, "a > 1 &&\nb > 2\n", ""},
+		{[]string{"-l"}, `a > 1`, 0, "<stdin>\n", ""},
+		{[]string{"-l"}, "a > 1\n", 0, "", ""},
+		{nil, `a >`, 2, "", "<stdin>: no prefix parse function for EOF found\n"},
+		{[]string{"-x"}, ``, 2, "", "flag provided but not defined: -x\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.input), &stdout, &stderr)
+		assert.Equal(t, tt.code, code, tt.input)
+		assert.Equal(t, tt.stdout, stdout.String(), tt.input)
+		if tt.code == 0 {
+			assert.Equal(t, tt.stderr, stderr.String(), tt.input)
+		} else {
+			assert.True(t, strings.HasPrefix(stderr.String(), tt.stderr), stderr.String())
+		}
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	formatted := filepath.Join(dir, "formatted.cond")
+	messy := filepath.Join(dir, "messy.cond")
+	broken := filepath.Join(dir, "broken.cond")
+	assert.Nil(t, ioutil.WriteFile(formatted, []byte("a > 1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(messy, []byte("(a>1)&&b"), 0644))
+	assert.Nil(t, ioutil.WriteFile(broken, []byte("a >"), 0644))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"-l", formatted, messy, broken}, nil, &stdout, &stderr))
+	assert.Equal(t, messy+"\n", stdout.String())
+	assert.Contains(t, stderr.String(), broken+": ")
+
+	// -w 只改写格式不同的文件
+	stdout.Reset()
+	stderr.Reset()
+	assert.Equal(t, 0, run([]string{"-w", formatted, messy}, nil, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+	assert.Empty(t, stderr.String())
+	data, err := ioutil.ReadFile(messy)
+	assert.Nil(t, err)
+	assert.Equal(t, "a > 1 && b\n", string(data))
+
+	stdout.Reset()
+	assert.Equal(t, 2, run([]string{filepath.Join(dir, "missing.cond")}, nil, &stdout, &stderr))
+}
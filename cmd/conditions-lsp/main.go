@@ -0,0 +1,38 @@
+// conditions-lsp 条件表达式的Language Server，通过标准输入输出通信
+//
+//	conditions-lsp [-schema schema.json]
+//
+// 每个文档是一个表达式，以.rules结尾的文档按照规则文件检查
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/bdjimmy/conditions"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "JSON schema of identifier types, e.g. {\"age\": \"int\"}")
+	flag.Parse()
+
+	var schema conditions.Schema
+	if *schemaPath != "" {
+		data, err := ioutil.ReadFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if schema, err = conditions.ParseSchema(data); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", *schemaPath, err)
+			os.Exit(2)
+		}
+	}
+	s := newServer(os.Stdin, os.Stdout, schema)
+	if err := s.serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bdjimmy/conditions"
+)
+
+// rulesExt 以此结尾的文档按照规则文件处理
+const rulesExt = ".rules"
+
+type server struct {
+	in     *bufio.Reader
+	out    io.Writer
+	mu     sync.Mutex // 保护out
+	schema conditions.Schema
+	docs   map[string]string // uri => 文档内容
+	exited bool
+}
+
+func newServer(in io.Reader, out io.Writer, schema conditions.Schema) *server {
+	return &server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		schema: schema,
+		docs:   make(map[string]string),
+	}
+}
+
+// serve 处理消息直到收到exit通知或者输入结束
+func (s *server) serve() error {
+	for !s.exited {
+		msg, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			// 通知不需要响应
+			continue
+		}
+		resp := &message{ID: msg.ID, Result: result, Error: rerr}
+		if rerr == nil && result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+		if err := s.send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) send(msg *message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.send(&message{Method: method, Params: raw})
+}
+
+func (s *server) handle(msg *message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1, // 每次发送完整的文档
+				"completionProvider":         map[string]interface{}{},
+				"hoverProvider":              true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]interface{}{"name": "conditions-lsp"},
+		}, nil
+	case "initialized", "$/cancelRequest", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		s.exited = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/completion":
+		return s.completion(), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		return s.hover(params), nil
+	case "textDocument/formatting":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		return s.format(params.TextDocument.URI), nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s not found", msg.Method)}
+}
+
+// update 更新文档内容并发布诊断信息
+func (s *server) update(uri, text string) *responseError {
+	s.docs[uri] = text
+	diags := []diagnostic{}
+	for _, d := range s.lint(uri, text) {
+		start := lineColumnToOffset(text, d.Line, d.Column)
+		severity := severityError
+		if d.Severity == conditions.SeverityWarning {
+			severity = severityWarning
+		}
+		diags = append(diags, diagnostic{
+			Range: lspRange{
+				Start: offsetToPosition(text, start),
+				End:   offsetToPosition(text, tokenEnd(text, start)),
+			},
+			Severity: severity,
+			Code:     d.Code,
+			Source:   "conditions",
+			Message:  d.Message,
+		})
+	}
+	err := s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+	if err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *server) lint(uri, text string) []conditions.Diagnostic {
+	opts := conditions.LintOptions{Schema: s.schema}
+	if strings.HasSuffix(uri, rulesExt) {
+		rules, diags := conditions.ParseRules(uri, []byte(text))
+		return append(diags, conditions.Lint(rules, opts)...)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	rule := conditions.RuleSource{ID: uri, Expr: text, File: uri, Line: 1, Column: 1}
+	return conditions.Lint([]conditions.RuleSource{rule}, opts)
+}
+
+func (s *server) completion() []completionItem {
+	items := []completionItem{}
+	for _, name := range s.schema.Names() {
+		items = append(items, completionItem{Label: name, Kind: completionVariable, Detail: string(s.schema[name])})
+	}
+	signatures := conditions.BuiltinSignatures()
+	names := make([]string, 0, len(signatures))
+	for name := range signatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, completionItem{
+			Label:  name,
+			Kind:   completionFunction,
+			Detail: strings.Join(signatures[name], "\n"),
+		})
+	}
+	for _, keyword := range []string{"true", "false", "in"} {
+		items = append(items, completionItem{Label: keyword, Kind: completionKeyword})
+	}
+	return items
+}
+
+// hover 显示光标处标识符、内置函数或字面量的类型
+func (s *server) hover(params textDocumentPositionParams) interface{} {
+	text, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	offset := positionToOffset(text, params.Position)
+	tok, next, ok := tokenAt(text, offset)
+	if !ok {
+		return nil
+	}
+	var contents string
+	switch tok.Type {
+	case conditions.IDENT:
+		if signatures, ok := conditions.BuiltinSignatures()[tok.Literal]; ok && next.Type == conditions.LPAREN {
+			contents = strings.Join(signatures, "\n")
+			if t := s.callType(text, tok.Pos); t != "" {
+				contents += "\n\n" + "returns " + t
+			}
+		} else if t, ok := s.schema[tok.Literal]; ok {
+			contents = fmt.Sprintf("%s: %s", tok.Literal, t)
+		} else {
+			contents = fmt.Sprintf("%s: undeclared identifier", tok.Literal)
+		}
+	case conditions.INT:
+		contents = string(conditions.INTEGER_OBJ)
+	case conditions.FLOAT:
+		contents = string(conditions.FLOAT_OBJ)
+	case conditions.STRING:
+		contents = string(conditions.STRING_OBJ)
+	case conditions.TRUE, conditions.FALSE:
+		contents = string(conditions.BOOLEAN_OBJ)
+	case conditions.TIME:
+		contents = string(conditions.TIME_OBJ)
+	case conditions.DURATION:
+		contents = string(conditions.DURATION_OBJ)
+	default:
+		return nil
+	}
+	return map[string]interface{}{
+		"contents": map[string]interface{}{"kind": "plaintext", "value": contents},
+		"range": lspRange{
+			Start: offsetToPosition(text, tok.Pos),
+			End:   offsetToPosition(text, tokenEnd(text, tok.Pos)),
+		},
+	}
+}
+
+// callType 返回从pos开始的函数调用推断出的返回类型
+func (s *server) callType(text string, pos int) string {
+	p := conditions.NewParser(conditions.NewLexer(text))
+	p.SetSchema(s.schema)
+	program := p.ParseProgram()
+	var t conditions.ObjectType
+	conditions.Inspect(program, func(node conditions.Node) bool {
+		if call, ok := node.(*conditions.CallExpression); ok && p.Pos(call) == pos {
+			t = p.CheckType(call)
+		}
+		return t == ""
+	})
+	if t == conditions.ERROR_OBJ {
+		return ""
+	}
+	return string(t)
+}
+
+// format 格式化表达式文档，有错误时不修改
+func (s *server) format(uri string) []textEdit {
+	text, ok := s.docs[uri]
+	if !ok || strings.HasSuffix(uri, rulesExt) {
+		return nil
+	}
+	p := conditions.NewParser(conditions.NewLexer(text))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 || program.Expression == nil {
+		return []textEdit{}
+	}
+	formatted := conditions.Format(program) + "\n"
+	if formatted == text {
+		return []textEdit{}
+	}
+	return []textEdit{{
+		Range:   lspRange{Start: position{}, End: offsetToPosition(text, len(text))},
+		NewText: formatted,
+	}}
+}
+
+// tokenAt 返回覆盖offset的词法单元以及它后面的词法单元
+func tokenAt(text string, offset int) (conditions.Token, conditions.Token, bool) {
+	l := conditions.NewLexer(text)
+	for tok := l.NextToken(); tok.Type != conditions.EOF; {
+		next := l.NextToken()
+		if tok.Pos <= offset && offset < tokenEnd(text, tok.Pos) {
+			return tok, next, true
+		}
+		tok = next
+	}
+	return conditions.Token{}, conditions.Token{}, false
+}
+
+// tokenEnd 返回从offset开始的词法单元的结束位置
+func tokenEnd(text string, offset int) int {
+	if offset >= len(text) {
+		return len(text)
+	}
+	tok := conditions.NewLexer(text[offset:]).NextToken()
+	end := offset + tok.Pos + len(tok.Literal)
+	if tok.Type == conditions.STRING {
+		end = stringEnd(text, offset+tok.Pos)
+	}
+	if end <= offset {
+		end = offset + 1
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	return end
+}
+
+// stringEnd 返回从start处的引号开始的字符串字面量的结束位置，包括两侧的引号和转义字符
+func stringEnd(text string, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) && (text[i+1] == '"' || text[i+1] == '\\') {
+				i++
+			}
+		case '"':
+			return i + 1
+		}
+	}
+	return len(text)
+}
+
+// lineColumnToOffset 将从1开始的行号和字节列号转换为字节偏移
+func lineColumnToOffset(text string, line, column int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := strings.Index(text[offset:], "\n")
+		if next < 0 {
+			return len(text)
+		}
+		offset += next + 1
+	}
+	offset += column - 1
+	if offset > len(text) {
+		offset = len(text)
+	}
+	return offset
+}
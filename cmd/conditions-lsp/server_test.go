@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/bdjimmy/conditions"
+	"github.com/stretchr/testify/assert"
+)
+
+// client 通过管道和server通信的脚本客户端
+type client struct {
+	t    *testing.T
+	w    io.WriteCloser
+	r    *bufio.Reader
+	id   int
+	errc chan error
+}
+
+func newClient(t *testing.T, schema conditions.Schema) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, r: bufio.NewReader(outR), errc: make(chan error, 1)}
+	go func() {
+		c.errc <- newServer(inR, outW, schema).serve()
+		outW.Close()
+	}()
+	return c
+}
+
+func (c *client) write(id *json.RawMessage, method string, params interface{}) {
+	raw, err := json.Marshal(params)
+	assert.Nil(c.t, err)
+	assert.Nil(c.t, writeMessage(c.w, &message{ID: id, Method: method, Params: raw}))
+}
+
+// call 发送请求并返回响应的结果
+func (c *client) call(method string, params interface{}, result interface{}) *responseError {
+	c.id++
+	id := json.RawMessage(fmt.Sprint(c.id))
+	c.write(&id, method, params)
+	resp := c.read()
+	assert.Equal(c.t, string(id), string(*resp.ID))
+	if result != nil && resp.Result != nil {
+		raw, _ := json.Marshal(resp.Result)
+		assert.Nil(c.t, json.Unmarshal(raw, result))
+	}
+	return resp.Error
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.write(nil, method, params)
+}
+
+func (c *client) read() *message {
+	msg, err := readMessage(c.r)
+	if !assert.Nil(c.t, err) {
+		c.t.FailNow()
+	}
+	return msg
+}
+
+// diagnostics 读取一个publishDiagnostics通知
+func (c *client) diagnostics() []diagnostic {
+	msg := c.read()
+	assert.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+	var params struct {
+		Diagnostics []diagnostic `json:"diagnostics"`
+	}
+	assert.Nil(c.t, json.Unmarshal(msg.Params, &params))
+	return params.Diagnostics
+}
+
+func (c *client) open(uri, text string) []diagnostic {
+	params := map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "text": text}}
+	c.notify("textDocument/didOpen", params)
+	return c.diagnostics()
+}
+
+func (c *client) close() {
+	assert.Nil(c.t, c.call("shutdown", nil, nil))
+	c.notify("exit", nil)
+	assert.Nil(c.t, <-c.errc)
+}
+
+var lspSchema = conditions.Schema{
+	"age":     conditions.INTEGER_OBJ,
+	"country": conditions.STRING_OBJ,
+	"vip":     conditions.BOOLEAN_OBJ,
+}
+
+func TestServerInitialize(t *testing.T) {
+	c := newClient(t, lspSchema)
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	assert.Nil(t, c.call("initialize", map[string]interface{}{}, &result))
+	assert.Equal(t, float64(1), result.Capabilities["textDocumentSync"])
+	assert.Equal(t, true, result.Capabilities["hoverProvider"])
+	assert.Equal(t, true, result.Capabilities["documentFormattingProvider"])
+
+	err := c.call("unknown/method", nil, nil)
+	assert.Equal(t, codeMethodNotFound, err.Code)
+	c.close()
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	c := newClient(t, lspSchema)
+	diags := c.open("file:///a.cond", `age > 18 && country == 1`)
+	if assert.NotEmpty(t, diags) {
+		last := diags[len(diags)-1]
+		assert.Equal(t, severityError, last.Severity)
+		assert.Equal(t, "type-mismatch", last.Code)
+		assert.Equal(t, lspRange{Start: position{0, 23}, End: position{0, 24}}, last.Range)
+	}
+
+	diags = c.open("file:///b.cond", "age > 18 &&\n  name == \"x\"")
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, "unknown-identifier", diags[0].Code)
+		assert.Equal(t, lspRange{Start: position{1, 2}, End: position{1, 6}}, diags[0].Range)
+	}
+
+	// 修改后错误消失
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///b.cond"},
+		"contentChanges": []map[string]interface{}{{"text": `age > 18`}},
+	})
+	assert.Empty(t, c.diagnostics())
+
+	diags = c.open("file:///c.rules", "adult: age >= 18\nbad: age >\n")
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, "syntax", diags[0].Code)
+		assert.Equal(t, 1, diags[0].Range.Start.Line)
+	}
+	c.close()
+}
+
+func TestServerCompletion(t *testing.T) {
+	c := newClient(t, lspSchema)
+	c.open("file:///a.cond", "")
+	var items []completionItem
+	assert.Nil(t, c.call("textDocument/completion", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": "file:///a.cond"},
+		"position":     position{0, 0},
+	}, &items))
+	labels := map[string]completionItem{}
+	for _, item := range items {
+		labels[item.Label] = item
+	}
+	assert.Equal(t, completionItem{Label: "age", Kind: completionVariable, Detail: "INTEGER"}, labels["age"])
+	assert.Equal(t, completionFunction, labels["len"].Kind)
+	assert.Contains(t, labels["len"].Detail, "len(")
+	assert.Equal(t, completionKeyword, labels["in"].Kind)
+	c.close()
+}
+
+func TestServerHover(t *testing.T) {
+	c := newClient(t, lspSchema)
+	c.open("file:///a.cond", `len(country) > age`)
+	hover := func(character int) string {
+		var result struct {
+			Contents struct {
+				Value string `json:"value"`
+			} `json:"contents"`
+		}
+		assert.Nil(t, c.call("textDocument/hover", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///a.cond"},
+			"position":     position{0, character},
+		}, &result))
+		return result.Contents.Value
+	}
+	assert.Contains(t, hover(1), "returns INTEGER")
+	assert.Equal(t, "country: STRING", hover(6))
+	assert.Equal(t, "age: INTEGER", hover(16))
+	assert.Equal(t, "", hover(13))
+	c.close()
+}
+
+func TestServerFormatting(t *testing.T) {
+	c := newClient(t, lspSchema)
+	c.open("file:///a.cond", "age>18&&(country==\"CN\")")
+	var edits []textEdit
+	params := map[string]interface{}{"textDocument": map[string]interface{}{"uri": "file:///a.cond"}}
+	assert.Nil(t, c.call("textDocument/formatting", params, &edits))
+	if assert.Len(t, edits, 1) {
+		assert.Equal(t, "age > 18 && country == \"CN\"\n", edits[0].NewText)
+		assert.Equal(t, position{0, 23}, edits[0].Range.End)
+	}
+	c.close()
+}
+
+func TestPositionOffset(t *testing.T) {
+	text := "a\n\"中文\" == 𝄞b"
+	for offset := range text {
+		pos := offsetToPosition(text, offset)
+		assert.Equal(t, offset, positionToOffset(text, pos))
+	}
+	assert.Equal(t, position{1, 10}, offsetToPosition(text, len(text)-1))
+}
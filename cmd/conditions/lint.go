@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bdjimmy/conditions"
+)
+
+// ruleFileExt 规则文件的扩展名，目录会被递归查找
+const ruleFileExt = ".rules"
+
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	schemaPath := fs.String("schema", "", "JSON schema of identifier types, e.g. {\"age\": \"int\"}")
+	format := fs.String("format", "text", "output format: text, json or sarif")
+	maxIn := fs.Int("max-in", conditions.DefaultMaxInItems, "maximum number of items in an in array")
+	fs.Parse(args)
+
+	opts := conditions.LintOptions{MaxInItems: *maxIn}
+	if *schemaPath != "" {
+		data, err := ioutil.ReadFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		if opts.Schema, err = conditions.ParseSchema(data); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", *schemaPath, err)
+			return exitError
+		}
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := ruleFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	var rules []conditions.RuleSource
+	var diags []conditions.Diagnostic
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		r, d := conditions.ParseRules(file, data)
+		rules = append(rules, r...)
+		diags = append(diags, d...)
+	}
+	diags = append(diags, conditions.Lint(rules, opts)...)
+
+	switch *format {
+	case "text":
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+	case "json":
+		err = writeJSON(os.Stdout, diags)
+	case "sarif":
+		err = writeJSON(os.Stdout, sarifReport(diags))
+	default:
+		fmt.Fprintf(os.Stderr, "conditions lint: unknown format %q\n", *format)
+		return exitError
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	for _, d := range diags {
+		if d.Severity == conditions.SeverityError {
+			return exitFalse
+		}
+	}
+	return exitTrue
+}
+
+// ruleFiles 展开参数中的目录，返回所有的规则文件
+func ruleFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && filepath.Ext(p) == ruleFileExt {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// sarifReport 生成SARIF 2.1.0格式的报告
+func sarifReport(diags []conditions.Diagnostic) interface{} {
+	type object = map[string]interface{}
+	rules := []object{}
+	seen := map[string]bool{}
+	results := []object{}
+	for _, d := range diags {
+		if !seen[d.Code] {
+			seen[d.Code] = true
+			rules = append(rules, object{"id": d.Code})
+		}
+		results = append(results, object{
+			"ruleId":  d.Code,
+			"level":   d.Severity,
+			"message": object{"text": d.Message},
+			"locations": []object{{
+				"physicalLocation": object{
+					"artifactLocation": object{"uri": filepath.ToSlash(d.File)},
+					"region":           object{"startLine": d.Line, "startColumn": d.Column},
+				},
+				"logicalLocations": []object{{"name": d.RuleID}},
+			}},
+		})
+	}
+	return object{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []object{{
+			"tool":    object{"driver": object{"name": "conditions-lint", "rules": rules}},
+			"results": results,
+		}},
+	}
+}
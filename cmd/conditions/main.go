@@ -0,0 +1,174 @@
+// conditions 条件表达式的命令行工具
+//
+//	conditions [repl]                          交互式求值
+//	conditions eval -e 'expr' [-vars vars.json] 求值，结果为false时退出码为1，出错时为2
+//	conditions lint [-schema schema.json] [-format text|json|sarif] [path ...]
+//	                                           检查规则文件，发现错误时退出码为1
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bdjimmy/conditions"
+)
+
+const (
+	exitTrue  = 0
+	exitFalse = 1
+	exitError = 2
+)
+
+func main() {
+	args := os.Args[1:]
+	cmd := "repl"
+	if len(args) != 0 {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "repl":
+		os.Exit(runRepl(args))
+	case "eval":
+		os.Exit(runEval(args, os.Stdout, os.Stderr))
+	case "lint":
+		os.Exit(runLint(args))
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "conditions: unknown command %q\n", cmd)
+		usage(os.Stderr)
+		os.Exit(exitError)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `usage:
+	conditions [repl] [-vars vars.json]
+	conditions eval -e 'expr' [-vars vars.json]
+	conditions lint [-schema schema.json] [-format text|json|sarif] [-max-in n] [path ...]
+
+eval exits with 0 when the result is true, 1 when it is false and 2 on errors.
+lint checks every *.rules file (lines of "<id>: <expression>") and exits with 1 when errors are found.
+`)
+}
+
+func runRepl(args []string) int {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	vars := fs.String("vars", "", "JSON file of variables")
+	fs.Parse(args)
+
+	loaded, err := loadVars(*vars)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	r := newRepl(os.Stdin, os.Stdout, loaded)
+	r.historyFile = historyPath()
+	r.run()
+	return exitTrue
+}
+
+func runEval(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	expr := fs.String("e", "", "expression to evaluate")
+	vars := fs.String("vars", "", "JSON file of variables")
+	quiet := fs.Bool("q", false, "do not print the result")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *expr == "" {
+		fmt.Fprintln(stderr, "conditions eval: -e is required")
+		return exitError
+	}
+
+	loaded, err := loadVars(*vars)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	program, ok := parse(stderr, *expr)
+	if !ok {
+		return exitError
+	}
+	result := conditions.Eval(program, newEnvironment(loaded))
+	if e, ok := result.(*conditions.Error); ok {
+		fmt.Fprintf(stderr, "error: %s\n", e.Message)
+		return exitError
+	}
+	if !*quiet {
+		fmt.Fprintln(stdout, inspect(result))
+	}
+	if b, ok := result.(*conditions.Boolean); ok && b.Value {
+		return exitTrue
+	}
+	return exitFalse
+}
+
+// parse 解析并检测表达式，有错误时输出带有位置标记的错误
+func parse(w io.Writer, input string) (*conditions.Program, bool) {
+	p := conditions.NewParser(conditions.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		return program, true
+	}
+	lines := strings.Split(input, "\n")
+	for _, e := range p.ErrorDetails() {
+		fmt.Fprintf(w, "error: %s\n", e)
+		if e.Pos < 0 || e.Line > len(lines) {
+			continue
+		}
+		fmt.Fprintf(w, "\t%s\n\t%s^\n", lines[e.Line-1], strings.Repeat(" ", e.Column-1))
+	}
+	return nil, false
+}
+
+// loadVars 从JSON文件中加载变量，path为空时不返回变量
+func loadVars(path string) (map[string]conditions.Object, error) {
+	loaded := map[string]conditions.Object{}
+	if path == "" {
+		return loaded, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for name, v := range vars {
+		obj, err := conditions.ToObject(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: variable %s: %s", path, name, err)
+		}
+		loaded[name] = obj
+	}
+	return loaded, nil
+}
+
+func newEnvironment(vars map[string]conditions.Object) *conditions.Environment {
+	env := conditions.NewEnvironment()
+	for name, obj := range vars {
+		env.Set(name, obj)
+	}
+	return env
+}
+
+// inspect 输出求值结果
+func inspect(obj conditions.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "nil"
+	case *conditions.Error:
+		return "error: " + obj.Message
+	case conditions.Node:
+		return obj.String()
+	}
+	return fmt.Sprintf("%v", obj)
+}
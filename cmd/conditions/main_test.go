@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunEval(t *testing.T) {
+	vars := filepath.Join(t.TempDir(), "vars.json")
+	assert.Nil(t, ioutil.WriteFile(vars, []byte(`{"age": 20, "tags": ["vip"]}`), 0644))
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-e", `age > 18 && "vip" in tags`, "-vars", vars}, exitTrue, "true\n", ""},
+		{[]string{"-e", `age > 30`, "-vars", vars}, exitFalse, "false\n", ""},
+		{[]string{"-q", "-e", `age > 30`, "-vars", vars}, exitFalse, "", ""},
+		{[]string{"-e", `age + 1`, "-vars", vars}, exitFalse, "21\n", ""},
+		{[]string{"-e", `name == "a"`}, exitError, "", "error: identifier not found: name\n"},
+		{[]string{"-e", `age >`}, exitError, "", "error: 1:6: no prefix parse function for EOF found\n\tage >\n\t     ^\n"},
+		{[]string{"-e", `age > 1`, "-vars", "missing.json"}, exitError, "", "open missing.json: no such file or directory\n"},
+		{[]string{}, exitError, "", "conditions eval: -e is required\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runEval(tt.args, &stdout, &stderr)
+		assert.Equal(t, tt.code, code, "%v", tt.args)
+		assert.Equal(t, tt.stdout, stdout.String(), "%v", tt.args)
+		assert.Equal(t, tt.stderr, stderr.String(), "%v", tt.args)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bdjimmy/conditions"
+)
+
+const (
+	prompt         = ">> "
+	continuePrompt = ".. "
+	maxHistory     = 1000
+)
+
+const replHelp = `enter an expression to evaluate it, unbalanced brackets or a trailing \ continue on the next line
+commands:
+	:set <name> <json>  bind a variable, e.g. :set tags ["a", "b"]
+	:unset <name>       remove a variable
+	:vars               list variables
+	:history            list history
+	!<n>                re-run history entry n
+	:help               show this help
+	:quit               exit
+`
+
+type repl struct {
+	in          *bufio.Scanner
+	out         io.Writer
+	env         *conditions.Environment
+	vars        map[string]conditions.Object // -vars加载的和通过:set设置的变量，用于:vars和:unset
+	history     []string
+	historyFile string
+}
+
+func newRepl(in io.Reader, out io.Writer, vars map[string]conditions.Object) *repl {
+	r := &repl{
+		in:   bufio.NewScanner(in),
+		out:  out,
+		vars: make(map[string]conditions.Object, len(vars)),
+	}
+	for name, obj := range vars {
+		r.vars[name] = obj
+	}
+	r.env = newEnvironment(r.vars)
+	return r
+}
+
+func (r *repl) run() {
+	r.loadHistory()
+	fmt.Fprintln(r.out, "conditions repl, :help for help")
+	for {
+		input, ok := r.readInput()
+		if !ok {
+			return
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		if strings.HasPrefix(input, "!") && len(input) > 1 && isDigits(input[1:]) {
+			n, _ := strconv.Atoi(input[1:])
+			if n < 1 || n > len(r.history) {
+				fmt.Fprintf(r.out, "no history entry %d\n", n)
+				continue
+			}
+			input = r.history[n-1]
+			fmt.Fprintln(r.out, input)
+		}
+		if input == ":quit" || input == ":q" {
+			return
+		}
+		r.addHistory(input)
+		if strings.HasPrefix(input, ":") {
+			r.command(input)
+			continue
+		}
+		r.eval(input)
+	}
+}
+
+// readInput 读取一个完整的输入，括号或引号未闭合、以\结尾时继续读取下一行
+func (r *repl) readInput() (string, bool) {
+	var lines []string
+	p := prompt
+	for {
+		fmt.Fprint(r.out, p)
+		if !r.in.Scan() {
+			return "", false
+		}
+		line := r.in.Text()
+		if strings.HasSuffix(line, "\\") {
+			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			p = continuePrompt
+			continue
+		}
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if strings.HasPrefix(strings.TrimSpace(input), ":") || balanced(input) {
+			return input, true
+		}
+		p = continuePrompt
+	}
+}
+
+func (r *repl) eval(input string) {
+	program, ok := parse(r.out, input)
+	if !ok {
+		return
+	}
+	fmt.Fprintln(r.out, inspect(conditions.Eval(program, r.env)))
+}
+
+func (r *repl) command(input string) {
+	fields := strings.Fields(input)
+	switch fields[0] {
+	case ":help", ":h":
+		fmt.Fprint(r.out, replHelp)
+	case ":set":
+		if len(fields) < 3 {
+			fmt.Fprintln(r.out, "usage: :set <name> <json>")
+			return
+		}
+		raw := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input[len(":set"):]), fields[1]))
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			fmt.Fprintf(r.out, "invalid json: %s\n", err)
+			return
+		}
+		obj, err := conditions.ToObject(v)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			return
+		}
+		r.env.Set(fields[1], obj)
+		r.vars[fields[1]] = obj
+	case ":unset":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.out, "usage: :unset <name>")
+			return
+		}
+		// Environment不支持删除，重新构造一个环境
+		delete(r.vars, fields[1])
+		r.env = newEnvironment(r.vars)
+	case ":vars":
+		names := make([]string, 0, len(r.vars))
+		for name := range r.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s = %s\n", name, inspect(r.vars[name]))
+		}
+	case ":history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, strings.Replace(h, "\n", "\n      ", -1))
+		}
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, :help for help\n", fields[0])
+	}
+}
+
+func (r *repl) addHistory(input string) {
+	r.history = append(r.history, input)
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+		// 超出上限时重写历史文件，避免文件无限增长
+		r.saveHistory()
+		return
+	}
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	// 多行输入以转义的换行保存
+	fmt.Fprintln(f, strconv.Quote(input))
+}
+
+// saveHistory 用内存中的历史覆盖历史文件，先写入临时文件再重命名
+func (r *repl) saveHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	tmp := r.historyFile + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	for _, h := range r.history {
+		fmt.Fprintln(w, strconv.Quote(h))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	os.Rename(tmp, r.historyFile)
+}
+
+func (r *repl) loadHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.Open(r.historyFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if h, err := strconv.Unquote(s.Text()); err == nil {
+			r.history = append(r.history, h)
+		}
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".conditions_history")
+}
+
+// balanced 判断括号和引号是否已经闭合
+func balanced(input string) bool {
+	depth := 0
+	inString := false
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; {
+		case c == '"':
+			inString = !inString
+		case inString && c == '\\':
+			// 跳过转义的字符，"a\"b" 中的引号不会结束字符串
+			i++
+		case inString:
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		}
+	}
+	return depth <= 0 && !inString
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bdjimmy/conditions"
+	"github.com/stretchr/testify/assert"
+)
+
+// runScript 依次输入lines，返回去掉提示符后的输出
+func runScript(vars map[string]conditions.Object, lines ...string) string {
+	var out bytes.Buffer
+	r := newRepl(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, vars)
+	r.run()
+	ret := strings.Replace(out.String(), continuePrompt, "", -1)
+	return strings.Replace(ret, prompt, "", -1)
+}
+
+func TestReplCommands(t *testing.T) {
+	vars := map[string]conditions.Object{"age": &conditions.Integer{Value: 20}}
+	out := runScript(vars,
+		`:set tags ["a", "b"]`,
+		`:vars`,
+		`age > 18 && "a" in tags`,
+		`:unset tags`,
+		`:vars`,
+		`age > 18`,
+		`:unset age`,
+		`:vars`,
+		`age > 18`,
+		`:history`,
+		`!6`,
+		`!99`,
+		`:what`,
+		`:quit`,
+		`age`,
+	)
+	assert.Equal(t, `conditions repl, :help for help
+age = 20
+tags = ["a","b"]
+true
+age = 20
+true
+error: identifier not found: age
+   1  :set tags ["a", "b"]
+   2  :vars
+   3  age > 18 && "a" in tags
+   4  :unset tags
+   5  :vars
+   6  age > 18
+   7  :unset age
+   8  :vars
+   9  age > 18
+  10  :history
+age > 18
+error: identifier not found: age
+no history entry 99
+unknown command :what, :help for help
+`, out)
+}
+
+func TestReplMultiLine(t *testing.T) {
+	out := runScript(nil,
+		`:set x 1`,
+		`(x > 0 &&`,
+		`  x < 2)`,
+		`x == \`,
+		`1`,
+		`x >`,
+	)
+	assert.Equal(t, `conditions repl, :help for help
+true
+true
+error: 1:4: no prefix parse function for EOF found
+	x >
+	   ^
+`, out)
+}
+
+func TestBalanced(t *testing.T) {
+	for input, ok := range map[string]bool{
+		`(a > 1`:                 false,
+		`(a > 1)`:                true,
+		`name == "a(b"`:          true,
+		`name == "a\"(b"`:        true,
+		`name == "a\"b`:          false,
+		`name == "a\\" && (x`:    false,
+		`name in ["a\\", "b]"]`:  true,
+		`name =~ "\\d+" && [1]`:  true,
+		`name == "\"" && f(x) )`: true,
+	} {
+		assert.Equal(t, ok, balanced(input), input)
+	}
+}
+
+func TestReplHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	lines := make([]string, 0, maxHistory+10)
+	for i := 0; i < maxHistory+10; i++ {
+		lines = append(lines, strconv.Itoa(i))
+	}
+	var out bytes.Buffer
+	r := newRepl(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, nil)
+	r.historyFile = file
+	r.run()
+
+	// 历史文件和内存中的历史一样只保留最近的maxHistory条
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	saved := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Equal(t, maxHistory, len(saved))
+	assert.Equal(t, `"10"`, saved[0])
+	assert.Equal(t, strconv.Quote(strconv.Itoa(maxHistory+9)), saved[len(saved)-1])
+
+	loaded := newRepl(strings.NewReader(""), &out, nil)
+	loaded.historyFile = file
+	loaded.loadHistory()
+	assert.Equal(t, lines[10:], loaded.history)
+}
@@ -0,0 +1,374 @@
+package conditions
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// ElasticOptions 翻译为Elasticsearch查询时的选项
+type ElasticOptions struct {
+	// Field 将标识符映射为索引中的字段名，为nil时直接使用标识符
+	Field func(ident string) string
+}
+
+// ToElastic 将Program翻译为Elasticsearch的query DSL
+// 返回的结果可以直接作为"query"字段序列化为JSON
+func ToElastic(program *Program, opts *ElasticOptions) (map[string]interface{}, error) {
+	t := &elasticTranslator{}
+	if opts != nil {
+		t.field = opts.Field
+	}
+	query := t.translate(program.Expression)
+	if len(t.errors) != 0 {
+		return nil, fmt.Errorf("cannot translate to elastic: %s", strings.Join(t.errors, "; "))
+	}
+	return query, nil
+}
+
+// 比较运算符到range查询参数的映射
+var elasticRanges = map[TokenType]string{
+	LT:       "lt",
+	LT_EQUAL: "lte",
+	GT:       "gt",
+	GT_EQUAL: "gte",
+}
+
+type elasticTranslator struct {
+	field  func(string) string
+	errors []string
+}
+
+func (t *elasticTranslator) errorf(format string, args ...interface{}) map[string]interface{} {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (t *elasticTranslator) fieldName(ident string) string {
+	if t.field != nil {
+		return t.field(ident)
+	}
+	return ident
+}
+
+func (t *elasticTranslator) translate(node Expression) map[string]interface{} {
+	switch n := node.(type) {
+	case *Identifier:
+		return t.term(n.Value, true)
+	case *Boolean:
+		if n.Value {
+			return map[string]interface{}{"match_all": map[string]interface{}{}}
+		}
+		return map[string]interface{}{"match_none": map[string]interface{}{}}
+	case *PrefixExpresion:
+		if n.Operator != BANG {
+			return t.errorf("unsupported prefix operator %s", n.Operator)
+		}
+		var query map[string]interface{}
+		if ident, ok := n.Right.(*Identifier); ok {
+			query = t.term(ident.Value, true)
+		} else {
+			query = t.translate(n.Right)
+		}
+		// 与 != 相同，否定条件中引用的字段都必须存在
+		names := map[string]struct{}{}
+		collectVariables(n.Right, names)
+		var filter []interface{}
+		for _, name := range sortedNames(names) {
+			filter = append(filter, t.exists(name))
+		}
+		return boolQuery("must_not", query, filter...)
+	case *InfixExpression:
+		return t.translateInfix(n)
+	case *CallExpression:
+		return t.translateCall(n)
+	}
+	return t.errorf("unsupported expression %s", node.String())
+}
+
+func (t *elasticTranslator) translateInfix(n *InfixExpression) map[string]interface{} {
+	switch n.Operator {
+	case AND:
+		var must []interface{}
+		for _, operand := range flattenChain(n, AND) {
+			must = append(must, t.translate(operand))
+		}
+		return map[string]interface{}{"bool": map[string]interface{}{"must": must}}
+	case OR:
+		var should []interface{}
+		for _, operand := range flattenChain(n, OR) {
+			should = append(should, t.translate(operand))
+		}
+		return map[string]interface{}{
+			"bool": map[string]interface{}{"should": should, "minimum_should_match": 1},
+		}
+	case IN:
+		ident, ok := n.Left.(*Identifier)
+		if !ok {
+			return t.errorf("left side of in must be a field, got %s", n.Left.String())
+		}
+		if arr, ok := n.Right.(*Array); ok {
+			if _, ok := cidrList(arr); ok {
+				return t.errorf("%s matches CIDRs, which elastic does not support", n.String())
+			}
+		}
+		values, ok := literalValue(n.Right)
+		if _, isArray := values.([]interface{}); !ok || !isArray {
+			return t.errorf("right side of in must be an array literal, got %s", n.Right.String())
+		}
+		return map[string]interface{}{"terms": map[string]interface{}{t.fieldName(ident.Value): values}}
+	case REG:
+		return t.regexp(n.Left, n.Right)
+	}
+
+	operator := n.Operator
+	left, right := n.Left, n.Right
+	if _, ok := right.(*Identifier); ok {
+		left, right = right, left
+		operator = flippedOperators[operator]
+	}
+	if call, ok := left.(*CallExpression); ok {
+		return t.errorf("unsupported builtin %s", call.Function.String())
+	}
+	ident, ok := left.(*Identifier)
+	if !ok {
+		return t.errorf("comparison %s must be between a field and a literal", n.String())
+	}
+	value, ok := literalValue(right)
+	if !ok {
+		return t.errorf("comparison %s must be between a field and a literal", n.String())
+	}
+	switch operator {
+	case EQ:
+		return t.term(ident.Value, value)
+	case NOT_EQ:
+		return boolQuery("must_not", t.term(ident.Value, value), t.exists(ident.Value))
+	case LT, LT_EQUAL, GT, GT_EQUAL:
+		return map[string]interface{}{
+			"range": map[string]interface{}{
+				t.fieldName(ident.Value): map[string]interface{}{elasticRanges[operator]: value},
+			},
+		}
+	}
+	return t.errorf("unsupported operator %s", n.Operator)
+}
+
+func (t *elasticTranslator) translateCall(n *CallExpression) map[string]interface{} {
+	switch name := n.Function.String(); name {
+	case "regexp":
+		if len(n.Arguments) != 2 {
+			return t.errorf("wrong number of argument to regexp. got=%d, want=2", len(n.Arguments))
+		}
+		return t.regexp(n.Arguments[0], n.Arguments[1])
+	default:
+		return t.errorf("unsupported builtin %s", name)
+	}
+}
+
+func (t *elasticTranslator) regexp(subject, pattern Expression) map[string]interface{} {
+	ident, ok := subject.(*Identifier)
+	if !ok {
+		return t.errorf("regexp subject must be a field, got %s", subject.String())
+	}
+	p, ok := pattern.(*String)
+	if !ok {
+		return t.errorf("regexp pattern must be a string, got %s", pattern.String())
+	}
+	re, err := luceneRegexp(p.Value)
+	if err != nil {
+		return t.errorf("regexp %q: %s", p.Value, err)
+	}
+	return map[string]interface{}{"regexp": map[string]interface{}{t.fieldName(ident.Value): re}}
+}
+
+// luceneReserved Lucene正则中需要转义的字符
+const luceneReserved = `.?+*|{}[]()"\#@&<>~`
+
+// luceneRegexp 将Go的正则转换为Lucene的正则
+//
+// Go的正则匹配字符串的一部分，Lucene的正则总是匹配整个字符串并且不支持^和$，
+// 开头和结尾的^、$被去掉，没有锚定的一侧补充.*；其它位置的锚点、\b、(?i)等无法表达的写法返回error
+func luceneRegexp(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var branches []*syntax.Regexp
+	if re.Op == syntax.OpAlternate {
+		branches = re.Sub
+	} else {
+		branches = []*syntax.Regexp{re}
+	}
+	var parts []string
+	for _, branch := range branches {
+		part, err := luceneBranch(branch)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return "(" + strings.Join(parts, ")|(") + ")", nil
+}
+
+// luceneBranch 转换一个分支，处理开头和结尾的锚点
+func luceneBranch(re *syntax.Regexp) (string, error) {
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	prefix, suffix := ".*", ".*"
+	if len(subs) != 0 && subs[0].Op == syntax.OpBeginText {
+		prefix, subs = "", subs[1:]
+	}
+	if len(subs) != 0 && subs[len(subs)-1].Op == syntax.OpEndText {
+		suffix, subs = "", subs[:len(subs)-1]
+	}
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, sub := range subs {
+		if err := writeLucene(&b, sub); err != nil {
+			return "", err
+		}
+	}
+	b.WriteString(suffix)
+	return b.String(), nil
+}
+
+func writeLucene(b *strings.Builder, re *syntax.Regexp) error {
+	if re.Flags&syntax.FoldCase != 0 {
+		return fmt.Errorf("case-insensitive match is not supported")
+	}
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		b.WriteString("()")
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if strings.ContainsRune(luceneReserved, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte('.')
+	case syntax.OpCharClass:
+		writeLuceneClass(b, re.Rune)
+	case syntax.OpCapture:
+		if re.Sub[0].Op == syntax.OpAlternate {
+			return writeLucene(b, re.Sub[0])
+		}
+		b.WriteByte('(')
+		if err := writeLucene(b, re.Sub[0]); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if err := writeLuceneAtom(b, re.Sub[0]); err != nil {
+			return err
+		}
+		switch re.Op {
+		case syntax.OpStar:
+			b.WriteByte('*')
+		case syntax.OpPlus:
+			b.WriteByte('+')
+		case syntax.OpQuest:
+			b.WriteByte('?')
+		default:
+			switch {
+			case re.Max == re.Min:
+				fmt.Fprintf(b, "{%d}", re.Min)
+			case re.Max < 0:
+				fmt.Fprintf(b, "{%d,}", re.Min)
+			default:
+				fmt.Fprintf(b, "{%d,%d}", re.Min, re.Max)
+			}
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := writeLucene(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		b.WriteByte('(')
+		for i, sub := range re.Sub {
+			if i != 0 {
+				b.WriteByte('|')
+			}
+			if err := writeLucene(b, sub); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+	case syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine, syntax.OpEndLine:
+		return fmt.Errorf("anchors are only supported at the beginning or end of the pattern")
+	default:
+		return fmt.Errorf("%s is not supported", re.String())
+	}
+	return nil
+}
+
+// writeLuceneAtom 重复的对象不是单个字符时加上括号
+func writeLuceneAtom(b *strings.Builder, re *syntax.Regexp) error {
+	switch {
+	case re.Op == syntax.OpLiteral && len(re.Rune) == 1,
+		re.Op == syntax.OpCharClass, re.Op == syntax.OpAnyChar, re.Op == syntax.OpAnyCharNotNL,
+		re.Op == syntax.OpCapture:
+		return writeLucene(b, re)
+	}
+	b.WriteByte('(')
+	if err := writeLucene(b, re); err != nil {
+		return err
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// writeLuceneClass 字符类，覆盖到最大字符的类使用取反的写法
+func writeLuceneClass(b *strings.Builder, ranges []rune) {
+	b.WriteByte('[')
+	if len(ranges) != 0 && ranges[0] == 0 && ranges[len(ranges)-1] == unicode.MaxRune {
+		b.WriteByte('^')
+		negated := make([]rune, 0, len(ranges))
+		for i := 1; i+1 < len(ranges); i += 2 {
+			negated = append(negated, ranges[i]+1, ranges[i+1]-1)
+		}
+		ranges = negated
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		writeLuceneClassRune(b, ranges[i])
+		if ranges[i+1] != ranges[i] {
+			b.WriteByte('-')
+			writeLuceneClassRune(b, ranges[i+1])
+		}
+	}
+	b.WriteByte(']')
+}
+
+func writeLuceneClassRune(b *strings.Builder, r rune) {
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		b.WriteByte('\\')
+	}
+	b.WriteRune(r)
+}
+
+func (t *elasticTranslator) term(ident string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{t.fieldName(ident): value}}
+}
+
+// exists 求值时未绑定的标识符会导致不匹配，否定条件需要要求字段存在
+func (t *elasticTranslator) exists(ident string) map[string]interface{} {
+	return map[string]interface{}{"exists": map[string]interface{}{"field": t.fieldName(ident)}}
+}
+
+// boolQuery 构造bool查询，must_not之外的查询放入filter中
+func boolQuery(clause string, query map[string]interface{}, filter ...interface{}) map[string]interface{} {
+	b := map[string]interface{}{clause: []interface{}{query}}
+	if len(filter) != 0 {
+		b["filter"] = filter
+	}
+	return map[string]interface{}{"bool": b}
+}
@@ -0,0 +1,48 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToElasticGolden(t *testing.T) {
+	opts := &ElasticOptions{
+		Field: func(ident string) string { return "user." + ident },
+	}
+	testGolden(t, "elastic", func(program *Program) (interface{}, error) {
+		return ToElastic(program, opts)
+	})
+}
+
+func TestToElasticUnsupported(t *testing.T) {
+	program := NewParser(NewLexer(`len(name) > 1 || zero(name)`)).ParseProgram()
+	_, err := ToElastic(program, nil)
+	assert.EqualError(t, err, `cannot translate to elastic: unsupported builtin len; unsupported builtin zero`)
+
+	program = NewParser(NewLexer(`name ~= "(?i)bob"`)).ParseProgram()
+	_, err = ToElastic(program, nil)
+	assert.EqualError(t, err, `cannot translate to elastic: regexp "(?i)bob": case-insensitive match is not supported`)
+}
+
+func TestLuceneRegexp(t *testing.T) {
+	for pattern, expect := range map[string]string{
+		`^[a-z]+@example\.com$`: `[a-z]+\@example\.com`,
+		`abc`:                   `.*abc.*`,
+		`^abc`:                  `abc.*`,
+		`(foo|bar)$`:            `.*(foo|bar)`,
+		`^a|b$`:                 `(a.*)|(.*b)`,
+		`^[^0-9]{2,}x?$`:        `[^0-9]{2,}x?`,
+		`^(ab)+c{3}$`:           `(ab)+c{3}`,
+		`^(?:ab)*$`:             `(ab)*`,
+		`a<b>`:                  `.*a\<b\>.*`,
+	} {
+		got, err := luceneRegexp(pattern)
+		assert.Nil(t, err, pattern)
+		assert.Equal(t, expect, got, pattern)
+	}
+	for _, pattern := range []string{`a^b`, `\bword`, `(?i)abc`, `(?m)^a$`} {
+		_, err := luceneRegexp(pattern)
+		assert.NotNil(t, err, pattern)
+	}
+}
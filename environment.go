@@ -8,6 +8,7 @@ import (
 type Environment struct {
 	store    map[string]Object
 	readOnly map[string]struct{}
+	outer    *Environment // 外层作用域，查找不到时继续在外层查找
 }
 
 func NewEnvironment() *Environment {
@@ -17,8 +18,19 @@ func NewEnvironment() *Environment {
 	}
 }
 
+// NewEnclosedEnvironment 创建嵌套的作用域，例如量词中的循环变量
+// 内层Set的值只在内层可见，可以遮蔽外层的同名标识符
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
 func (env *Environment) Get(name string) (Object, bool) {
 	obj, ok := env.store[name]
+	if !ok && env.outer != nil {
+		return env.outer.Get(name)
+	}
 	return obj, ok
 }
 
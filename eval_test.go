@@ -2,7 +2,9 @@ package conditions
 
 import (
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,11 +19,85 @@ func TestEval(t *testing.T) {
 	assert.Equal(t, 0, len(p.errors))
 
 	env := NewEnvironment()
-	env.Set("abc", &ArrayInteger{
-		Value: []int64{1, 2, 3, 4, 5, 6},
-	})
+	env.Set("abc", NewIntegerArray(1, 2, 3, 4, 5, 6))
 	env.Set("S", &Integer{
 		Value: 123,
 	})
 	fmt.Println(Eval(program, env))
 }
+
+// 不是通过nativeBoolToBooleanObject得到的Boolean也要按值取反
+func TestEvalBangByValue(t *testing.T) {
+	program := NewParser(NewLexer(`!vip`)).ParseProgram()
+	env := NewEnvironment()
+	env.Set("vip", &Boolean{Value: false})
+	assert.Equal(t, boolTrue, Eval(program, env))
+	env.Set("vip", &Boolean{Value: true})
+	assert.Equal(t, boolFalse, Eval(program, env))
+}
+
+func TestEvalStringComparison(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("name", &String{Value: "bob"})
+	for input, expect := range map[string]bool{
+		`name > "alice"`: true,
+		`name < "alice"`: false,
+		`"1.10" < "1.9"`: true,
+		`name > "bob"`:   false,
+		`"" < name`:      true,
+		`name >= "bob"`:  true,
+		`name <= "bo"`:   false,
+		`"b" <= name`:    true,
+	} {
+		program, err := Compile(input)
+		if !assert.Nil(t, err, input) {
+			continue
+		}
+		assert.Equal(t, nativeBoolToBooleanObject(expect), Eval(program, env), input)
+	}
+}
+
+// 字符串中 \" 和 \\ 是转义，和之前把反斜杠原样保留的写法不兼容
+func TestStringEscapes(t *testing.T) {
+	for input, expect := range map[string]string{
+		`"a\b"`:      `a\b`,
+		`"^\d+\.$"`:  `^\d+\.$`,
+		`"a\\b"`:     `a\b`, // 之前是a\\b
+		`"\\d"`:      `\d`,  // 之前是\\d
+		`"say \"hi"`: `say "hi`,
+		`"a\\"`:      `a\`,
+		`"a\"`:       `a"`, // 之前是a\，现在引号被转义，字符串没有结束
+	} {
+		program := NewParser(NewLexer(input)).ParseProgram()
+		assert.Equal(t, &String{Value: expect}, program.Expression, input)
+	}
+
+	env := NewEnvironment()
+	env.Set("name", &String{Value: "5"})
+	assert.Equal(t, boolTrue, Eval(mustCompile(t, `name ~= "^\\d$"`), env))
+	env.Set("name", &String{Value: `\d`})
+	assert.Equal(t, boolFalse, Eval(mustCompile(t, `name ~= "^\\d$"`), env))
+	// 匹配反斜杠需要在正则中写成 \\\\
+	assert.Equal(t, boolTrue, Eval(mustCompile(t, `name ~= "^\\\\d$"`), env))
+}
+
+func TestNegativeLiterals(t *testing.T) {
+	for input, expect := range map[string]Expression{
+		`-5`:                   &Integer{Value: -5},
+		`-9223372036854775808`: &Integer{Value: math.MinInt64},
+		`-1.5`:                 &Float{Value: -1.5},
+		`-7d1ns`:               &Duration{Value: -7*24*time.Hour - time.Nanosecond},
+		`3 - -2`:               &InfixExpression{Left: &Integer{Value: 3}, Operator: MINUS, Right: &Integer{Value: -2}},
+	} {
+		p := NewParser(NewLexer(input))
+		program := p.ParseProgram()
+		if assert.Empty(t, p.Errors(), input) {
+			assert.Equal(t, expect, program.Expression, input)
+		}
+	}
+	for _, input := range []string{`-x`, `--5`, `-(1)`, `-"a"`, `-9223372036854775809`} {
+		p := NewParser(NewLexer(input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors(), input)
+	}
+}
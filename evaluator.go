@@ -10,15 +10,24 @@ func Eval(node Node, env *Environment) Object {
 		return node
 	case *String:
 		return node
-	case *ArrayString:
+	case *Float:
 		return node
-	case *ArrayInteger:
+	case *Array:
+		return evalArray(node, env)
+	case *Time:
+		return node
+	case *Duration:
+		return node
+	case *IP:
 		return node
 	case *Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *Identifier:
 		return evalIdentifier(node, env)
 	case *CallExpression:
+		if q, ok := quantifiers[node.Function.String()]; ok {
+			return evalQuantifier(q, node, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -77,11 +86,8 @@ func evalPrefixOperatorExpression(operator TokenType, right Object) Object {
 
 // 执行 !<expression>
 func evalBangOperatorExpression(right Object) Object {
-	switch right {
-	case boolTrue:
-		return boolFalse
-	case boolFalse:
-		return boolTrue
+	if b, ok := right.(*Boolean); ok {
+		return nativeBoolToBooleanObject(!b.Value)
 	}
 	return boolFalse
 }
@@ -111,8 +117,17 @@ func evalInfixExpression(operator TokenType, left, right Object) Object {
 	switch {
 	case left.ObjectType() == INTEGER_OBJ && right.ObjectType() == INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.ObjectType() == STRING_OBJ && right.ObjectType() == STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.ObjectType() == BOOLEAN_OBJ && right.ObjectType() == BOOLEAN_OBJ &&
+		(operator == EQ || operator == NOT_EQ):
+		return evalBooleanInfixExpression(operator, left, right)
+	case left.ObjectType() == IP_OBJ:
+		return evalIPInfixExpression(operator, left, right)
+	case isTemporal(left) && isTemporal(right):
+		return evalTemporalInfixExpression(operator, left, right)
 	case operator == AND:
 		return nativeBoolToBooleanObject(objectToNativeBoolean(left) && objectToNativeBoolean(right))
 	case operator == OR:
@@ -126,38 +141,76 @@ func evalInfixExpression(operator TokenType, left, right Object) Object {
 }
 
 func evalINInfixExpress(left, right Object) Object {
-	switch {
-	case left.ObjectType() == INTEGER_OBJ && right.ObjectType() == ARRAY_INTEGER_OBJ:
-		leftVal := left.(*Integer).Value
-		rightVal := right.(*ArrayInteger).Value
-		for _, val := range rightVal {
-			if leftVal == val {
-				return &Boolean{
-					Value: true,
-				}
-			}
+	arr, ok := right.(*Array)
+	if !ok {
+		return newError("unknow operator: %s %s %s",
+			left.ObjectType(), "IN", right.ObjectType())
+	}
+	if s, ok := left.(*String); ok {
+		if matched, ok := stringInNetworks(s.Value, arr); ok {
+			return nativeBoolToBooleanObject(matched)
 		}
-		return &Boolean{
-			Value: false,
+	}
+	for _, elem := range arr.Elements {
+		if objectsEqual(left, elem.(Object)) {
+			return boolTrue
 		}
-	case left.ObjectType() == STRING_OBJ && right.ObjectType() == ARRAY_STRING_OBJ:
-		leftVal := left.(*String).Value
-		rightVal := right.(*ArrayString).Value
-		for _, val := range rightVal {
-			if leftVal == val {
-				return &Boolean{
-					Value: true,
-				}
-			}
+	}
+	return boolFalse
+}
+
+// evalArray 字面量数组直接返回，否则依次对元素求值
+func evalArray(node *Array, env *Environment) Object {
+	if isLiteral(node) {
+		return node
+	}
+	elems := make([]Expression, 0, len(node.Elements))
+	for _, e := range node.Elements {
+		obj := Eval(e, env)
+		if isError(obj) {
+			return obj
 		}
-		return &Boolean{
-			Value: false,
+		elem, ok := obj.(Expression)
+		if !ok {
+			return newError("unsupported array element %s", obj.ObjectType())
 		}
-	default:
-		return newError("unknow operator: %s %s %s",
-			left.ObjectType(), "IN", right.ObjectType())
+		elems = append(elems, elem)
 	}
+	return NewArray(elems...)
+}
 
+// objectsEqual 判断两个值是否相等，整数和浮点数按照数值比较，其它不同类型的值总是不相等
+func objectsEqual(left, right Object) bool {
+	if isNumber(left) && isNumber(right) {
+		return toFloat(left) == toFloat(right)
+	}
+	if left.ObjectType() != right.ObjectType() {
+		return false
+	}
+	switch l := left.(type) {
+	case *String:
+		return l.Value == right.(*String).Value
+	case *Boolean:
+		return l.Value == right.(*Boolean).Value
+	case *Time:
+		return l.Value.Equal(right.(*Time).Value)
+	case *Duration:
+		return l.Value == right.(*Duration).Value
+	case *IP:
+		return l.Value.Equal(right.(*IP).Value)
+	case *Array:
+		r := right.(*Array)
+		if len(l.Elements) != len(r.Elements) {
+			return false
+		}
+		for i := range l.Elements {
+			if !objectsEqual(l.Elements[i].(Object), r.Elements[i].(Object)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
 
 func evalIntegerInfixExpression(operator TokenType, left, right Object) Object {
@@ -188,6 +241,14 @@ func evalIntegerInfixExpression(operator TokenType, left, right Object) Object {
 		return &Boolean{
 			Value: leftVal > rightVal,
 		}
+	case "<=":
+		return &Boolean{
+			Value: leftVal <= rightVal,
+		}
+	case ">=":
+		return &Boolean{
+			Value: leftVal >= rightVal,
+		}
 	case "==":
 		return &Boolean{
 			Value: leftVal == rightVal,
@@ -212,11 +273,19 @@ func evalStringInfixExpression(operator TokenType, left, right Object) Object {
 		}
 	case "<":
 		return &Boolean{
-			Value: len(leftVal) < len(rightVal),
+			Value: leftVal < rightVal,
 		}
 	case ">":
 		return &Boolean{
-			Value: len(leftVal) > len(rightVal),
+			Value: leftVal > rightVal,
+		}
+	case "<=":
+		return &Boolean{
+			Value: leftVal <= rightVal,
+		}
+	case ">=":
+		return &Boolean{
+			Value: leftVal >= rightVal,
 		}
 	case "==":
 		return &Boolean{
@@ -226,6 +295,22 @@ func evalStringInfixExpression(operator TokenType, left, right Object) Object {
 		return &Boolean{
 			Value: leftVal != rightVal,
 		}
+	case "~=":
+		return builtins["regexp"].Fn(left, right)
+	default:
+		return newError("unknow operator: %s %s %s",
+			left.ObjectType(), operator, right.ObjectType())
+	}
+}
+
+func evalBooleanInfixExpression(operator TokenType, left, right Object) Object {
+	leftVal := left.(*Boolean).Value
+	rightVal := right.(*Boolean).Value
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
 		return newError("unknow operator: %s %s %s",
 			left.ObjectType(), operator, right.ObjectType())
@@ -273,3 +358,39 @@ func objectToNativeBoolean(o Object) bool {
 		return true
 	}
 }
+
+// isNumber 整数和浮点数，至少有一个浮点数时按照浮点数运算
+func isNumber(obj Object) bool {
+	return obj.ObjectType() == INTEGER_OBJ || obj.ObjectType() == FLOAT_OBJ
+}
+
+func toFloat(obj Object) float64 {
+	if i, ok := obj.(*Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*Float).Value
+}
+
+func evalFloatInfixExpression(operator TokenType, left, right Object) Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
+	case PLUS:
+		return &Float{Value: leftVal + rightVal}
+	case MINUS:
+		return &Float{Value: leftVal - rightVal}
+	case LT:
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case GT:
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case LT_EQUAL:
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case GT_EQUAL:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case EQ:
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case NOT_EQ:
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+	return newError("unknow operator: %s %s %s", left.ObjectType(), operator, right.ObjectType())
+}
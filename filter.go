@@ -0,0 +1,140 @@
+package conditions
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Matcher 编译好的条件，用来过滤结构体或map[string]T的切片
+type Matcher struct {
+	program *Program
+}
+
+// NewMatcher 编译条件表达式
+func NewMatcher(input string) (*Matcher, error) {
+	program, err := Compile(input)
+	if err != nil {
+		return nil, err
+	}
+	return &Matcher{program: program}, nil
+}
+
+// Match 将record的字段绑定到环境中并求值，record为结构体或map[string]T
+func (m *Matcher) Match(record interface{}) (bool, error) {
+	return m.match(NewEnvironment(), reflect.ValueOf(record))
+}
+
+func (m *Matcher) match(env *Environment, record reflect.Value) (bool, error) {
+	if err := bindValue(env, record); err != nil {
+		return false, err
+	}
+	switch obj := Eval(m.program, env).(type) {
+	case *Error:
+		return false, obj
+	case *Boolean:
+		return obj.Value, nil
+	case nil:
+		return false, fmt.Errorf("%s evaluated to nothing", m.program.String())
+	default:
+		return objectToNativeBoolean(obj), nil
+	}
+}
+
+// each 依次对records中的元素求值，fn返回false时停止
+func (m *Matcher) each(records interface{}, fn func(i int, matched bool) bool) error {
+	v, err := sliceValue(records)
+	if err != nil {
+		return err
+	}
+	// nil指针字段不会绑定，每个元素使用新的环境，避免沿用上一个元素的值
+	for i := 0; i < v.Len(); i++ {
+		matched, err := m.match(NewEnvironment(), v.Index(i))
+		if err != nil {
+			return fmt.Errorf("record %d: %s", i, err)
+		}
+		if !fn(i, matched) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func sliceValue(records interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return v, fmt.Errorf("records must be a slice, got %T", records)
+	}
+	return v, nil
+}
+
+// Filter 返回records中满足条件的元素，返回值与records的类型相同
+func (m *Matcher) Filter(records interface{}) (interface{}, error) {
+	matched, _, err := m.Partition(records)
+	return matched, err
+}
+
+// Partition 将records按是否满足条件分为两个切片，保持原有的顺序
+func (m *Matcher) Partition(records interface{}) (matched, rest interface{}, err error) {
+	v, err := sliceValue(records)
+	if err != nil {
+		return nil, nil, err
+	}
+	yes := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 0)
+	no := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 0)
+	err = m.each(records, func(i int, ok bool) bool {
+		if ok {
+			yes = reflect.Append(yes, v.Index(i))
+		} else {
+			no = reflect.Append(no, v.Index(i))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return yes.Interface(), no.Interface(), nil
+}
+
+// Count 返回records中满足条件的元素个数
+func (m *Matcher) Count(records interface{}) (int, error) {
+	count := 0
+	err := m.each(records, func(_ int, ok bool) bool {
+		if ok {
+			count++
+		}
+		return true
+	})
+	return count, err
+}
+
+// Any 判断records中是否存在满足条件的元素
+func (m *Matcher) Any(records interface{}) (bool, error) {
+	found := false
+	err := m.each(records, func(_ int, ok bool) bool {
+		found = ok
+		return !ok
+	})
+	return found, err
+}
+
+// All 判断records中的元素是否都满足条件，records为空时返回true
+func (m *Matcher) All(records interface{}) (bool, error) {
+	all := true
+	err := m.each(records, func(_ int, ok bool) bool {
+		all = ok
+		return ok
+	})
+	return all, err
+}
+
+// Filter 编译input并返回records中满足条件的元素
+//
+//	adults, err := conditions.Filter(users, `age > 18 && country in ["CN", "SG"]`)
+//	for _, u := range adults.([]User) { ... }
+func Filter(records interface{}, input string) (interface{}, error) {
+	m, err := NewMatcher(input)
+	if err != nil {
+		return nil, err
+	}
+	return m.Filter(records)
+}
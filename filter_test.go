@@ -0,0 +1,101 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	Name    string
+	Age     int
+	Country string
+	Tags    []string
+	secret  string
+}
+
+var testUsers = []testUser{
+	{Name: "a", Age: 20, Country: "CN", Tags: []string{"vip"}},
+	{Name: "b", Age: 17, Country: "CN"},
+	{Name: "c", Age: 30, Country: "US"},
+	{Name: "d", Age: 40, Country: "SG", Tags: []string{"new", "vip"}},
+}
+
+func TestMatcher(t *testing.T) {
+	m, err := NewMatcher(`Age > 18 && Country in ["CN", "SG"]`)
+	assert.Nil(t, err)
+
+	matched, rest, err := m.Partition(testUsers)
+	assert.Nil(t, err)
+	assert.Equal(t, []testUser{testUsers[0], testUsers[3]}, matched)
+	assert.Equal(t, []testUser{testUsers[1], testUsers[2]}, rest)
+
+	count, err := m.Count(testUsers)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	any, err := m.Any(testUsers)
+	assert.Nil(t, err)
+	assert.True(t, any)
+
+	all, err := m.All(testUsers)
+	assert.Nil(t, err)
+	assert.False(t, all)
+
+	// map记录
+	ok, err := m.Match(map[string]interface{}{"Age": 19, "Country": "SG"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	vips, err := Filter(testUsers, `"vip" in Tags`)
+	assert.Nil(t, err)
+	assert.Len(t, vips, 2)
+
+	_, err = Filter(testUsers, `Age >`)
+	assert.NotNil(t, err)
+}
+
+type testProfile struct {
+	City string
+}
+
+type testOptional struct {
+	Age     *int
+	Profile *testProfile
+}
+
+func TestMatcherNilFields(t *testing.T) {
+	age := 30
+	records := []testOptional{{Age: &age}, {Age: nil}}
+	m, err := NewMatcher(`Age > 18`)
+	assert.Nil(t, err)
+	// nil指针字段不能沿用上一个元素的值，与单独Match的结果一致
+	_, err = m.Count(records)
+	assert.EqualError(t, err, "record 1: identifier not found: Age")
+	_, err = m.Match(records[1])
+	assert.EqualError(t, err, "identifier not found: Age")
+
+	records = []testOptional{{Profile: &testProfile{City: "Beijing"}}, {}}
+	m, err = NewMatcher(`Profile.City == "Beijing"`)
+	assert.Nil(t, err)
+	_, err = m.Count(records)
+	assert.EqualError(t, err, "record 1: identifier not found: Profile.City")
+}
+
+func BenchmarkMatcherFilter(b *testing.B) {
+	records := make([]testUser, 1000000)
+	countries := []string{"CN", "SG", "US", "JP"}
+	for i := range records {
+		records[i] = testUser{Name: "u", Age: i % 80, Country: countries[i%len(countries)]}
+	}
+	m, err := NewMatcher(`Age > 18 && Country in ["CN", "SG"]`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Filter(records); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
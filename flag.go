@@ -0,0 +1,292 @@
+package conditions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 求值结果的原因
+const (
+	ReasonTargetingMatch = "targeting_match" // 命中了定向规则
+	ReasonSplit          = "split"           // 命中了定向规则，按百分比分配
+	ReasonDefault        = "default"         // 没有命中任何规则
+	ReasonDisabled       = "disabled"        // 开关被关闭，返回默认值
+	ReasonError          = "error"           // 求值出错，返回默认值
+)
+
+// FlagSet 从JSON中加载的一组开关
+type FlagSet struct {
+	flags map[string]*Flag
+}
+
+// Flag 开关的定义
+type Flag struct {
+	Name     string                     `json:"-"`
+	Variants map[string]json.RawMessage `json:"variants"`           // 变体名 => 值，值可以是bool、字符串、整数或任意JSON
+	Default  string                     `json:"default"`            // 没有命中规则时返回的变体
+	Salt     string                     `json:"salt,omitempty"`     // 分桶使用的salt，默认为开关名
+	Disabled bool                       `json:"disabled,omitempty"` // 关闭后总是返回默认变体
+	Rules    []*FlagRule                `json:"rules,omitempty"`    // 按顺序匹配的定向规则
+
+	values map[string]interface{}
+}
+
+// FlagRule 定向规则，When为空时总是命中
+// 命中后返回Variant，或者按照BucketBy标识符的值分桶并按Split的比例返回变体
+type FlagRule struct {
+	ID       string       `json:"id"`
+	When     string       `json:"when,omitempty"`
+	Variant  string       `json:"variant,omitempty"`
+	Split    []FlagWeight `json:"split,omitempty"`
+	BucketBy string       `json:"bucket_by,omitempty"`
+
+	program *Program
+}
+
+// FlagWeight 百分比分配中一个变体的比例，所有比例之和为100
+type FlagWeight struct {
+	Variant string `json:"variant"`
+	Weight  int64  `json:"weight"`
+}
+
+// FlagResult 开关的求值结果
+type FlagResult struct {
+	Flag    string
+	Variant string
+	Value   interface{} // 变体的值，整数为int64，其它JSON值按照encoding/json的规则解析
+	RuleID  string      // 命中的规则，没有命中时为空
+	Reason  string
+}
+
+// LoadFlags 加载JSON格式的开关定义，所有的规则都会进行语法解析和类型检测
+//
+//	{"flags": {"new_checkout": {
+//		"variants": {"on": true, "off": false},
+//		"default": "off",
+//		"rules": [
+//			{"id": "staff", "when": "email_domain == \"corp.com\"", "variant": "on"},
+//			{"id": "rollout", "when": "country in [\"CN\", \"SG\"]", "bucket_by": "user_id",
+//			 "split": [{"variant": "on", "weight": 20}, {"variant": "off", "weight": 80}]}
+//		]
+//	}}}
+//
+// schema不为nil时按照schema检测标识符的类型
+func LoadFlags(data []byte, schema Schema) (*FlagSet, error) {
+	var raw struct {
+		Flags map[string]*Flag `json:"flags"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("load flags: %s", err)
+	}
+	set := &FlagSet{flags: make(map[string]*Flag, len(raw.Flags))}
+	var errs []string
+	for name, flag := range raw.Flags {
+		if flag == nil {
+			errs = append(errs, fmt.Sprintf("flag %s: empty definition", name))
+			continue
+		}
+		flag.Name = name
+		for _, err := range flag.compile(schema) {
+			errs = append(errs, fmt.Sprintf("flag %s: %s", name, err))
+		}
+		set.flags[name] = flag
+	}
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("load flags: %s", strings.Join(errs, "; "))
+	}
+	return set, nil
+}
+
+// compile 校验开关的定义并编译规则
+func (f *Flag) compile(schema Schema) []string {
+	var errs []string
+	if len(f.Variants) == 0 {
+		errs = append(errs, "no variants")
+	}
+	f.values = make(map[string]interface{}, len(f.Variants))
+	for name, raw := range f.Variants {
+		value, err := decodeVariant(raw)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("variant %s: %s", name, err))
+			continue
+		}
+		f.values[name] = value
+	}
+	if _, ok := f.Variants[f.Default]; !ok {
+		errs = append(errs, fmt.Sprintf("default variant %q is not defined", f.Default))
+	}
+	if f.Salt == "" {
+		f.Salt = f.Name
+	}
+	ids := map[string]bool{}
+	for i, rule := range f.Rules {
+		if rule == nil {
+			errs = append(errs, fmt.Sprintf("rule #%d: empty definition", i))
+			continue
+		}
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("#%d", i)
+		}
+		if ids[rule.ID] {
+			errs = append(errs, fmt.Sprintf("rule %s: duplicate id", rule.ID))
+		}
+		ids[rule.ID] = true
+		for _, err := range rule.compile(f, schema) {
+			errs = append(errs, fmt.Sprintf("rule %s: %s", rule.ID, err))
+		}
+	}
+	return errs
+}
+
+func (r *FlagRule) compile(f *Flag, schema Schema) []string {
+	var errs []string
+	if r.When != "" {
+		p := NewParser(NewLexer(r.When))
+		p.SetSchema(schema)
+		r.program = p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			if t := p.CheckType(r.program); t != BOOLEAN_OBJ && t != IDENT_OBJ {
+				errs = append(errs, fmt.Sprintf("when %q is %s, want a boolean expression", r.When, t))
+			}
+		}
+		for _, e := range p.ErrorDetails() {
+			errs = append(errs, fmt.Sprintf("when %q: %s", r.When, e))
+		}
+	}
+	switch {
+	case r.Variant != "" && len(r.Split) != 0:
+		errs = append(errs, "variant and split are mutually exclusive")
+	case r.Variant != "":
+		if _, ok := f.Variants[r.Variant]; !ok {
+			errs = append(errs, fmt.Sprintf("variant %q is not defined", r.Variant))
+		}
+	case len(r.Split) != 0:
+		var total int64
+		for _, w := range r.Split {
+			if _, ok := f.Variants[w.Variant]; !ok {
+				errs = append(errs, fmt.Sprintf("split variant %q is not defined", w.Variant))
+			}
+			if w.Weight < 0 {
+				errs = append(errs, fmt.Sprintf("split variant %q has negative weight %d", w.Variant, w.Weight))
+			}
+			total += w.Weight
+		}
+		if total != DefaultBuckets {
+			errs = append(errs, fmt.Sprintf("split weights sum to %d, want %d", total, DefaultBuckets))
+		}
+		if r.BucketBy == "" {
+			errs = append(errs, "split requires bucket_by")
+		} else if t, ok := schema[r.BucketBy]; schema != nil && (!ok || (t != STRING_OBJ && t != INTEGER_OBJ)) {
+			errs = append(errs, fmt.Sprintf("bucket_by %s must be a string or int identifier in schema", r.BucketBy))
+		}
+	default:
+		errs = append(errs, "either variant or split is required")
+	}
+	return errs
+}
+
+// decodeVariant 解析变体的值，整数解析为int64
+func decodeVariant(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := n.Float64()
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid number %s", n)
+		}
+		return f, nil
+	}
+	// 其它JSON值按照encoding/json的默认规则解析
+	v = nil
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Flag 返回开关的定义
+func (s *FlagSet) Flag(name string) (*Flag, bool) {
+	f, ok := s.flags[name]
+	return f, ok
+}
+
+// Names 返回所有开关的名称，已排序
+func (s *FlagSet) Names() []string {
+	names := make([]string, 0, len(s.flags))
+	for name := range s.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EvaluateFlag 按顺序匹配开关的定向规则，返回命中的变体、规则和原因
+// 求值出错时返回默认变体、ReasonError以及error
+func (s *FlagSet) EvaluateFlag(name string, env *Environment) (FlagResult, error) {
+	f, ok := s.flags[name]
+	if !ok {
+		return FlagResult{Flag: name, Reason: ReasonError}, fmt.Errorf("flag %s not found", name)
+	}
+	if f.Disabled {
+		return f.result(f.Default, "", ReasonDisabled), nil
+	}
+	for _, rule := range f.Rules {
+		if rule.program != nil {
+			obj := Eval(rule.program, env)
+			if err, ok := obj.(*Error); ok {
+				return f.result(f.Default, rule.ID, ReasonError),
+					fmt.Errorf("flag %s rule %s: %s", name, rule.ID, err.Message)
+			}
+			if b, ok := obj.(*Boolean); !ok || !b.Value {
+				continue
+			}
+		}
+		if rule.Variant != "" {
+			return f.result(rule.Variant, rule.ID, ReasonTargetingMatch), nil
+		}
+		variant, err := f.split(rule, env)
+		if err != nil {
+			return f.result(f.Default, rule.ID, ReasonError), fmt.Errorf("flag %s rule %s: %s", name, rule.ID, err)
+		}
+		return f.result(variant, rule.ID, ReasonSplit), nil
+	}
+	return f.result(f.Default, "", ReasonDefault), nil
+}
+
+// split 按照bucket(BucketBy, Salt)的值和比例选择变体
+func (f *Flag) split(rule *FlagRule, env *Environment) (string, error) {
+	obj, ok := env.Get(rule.BucketBy)
+	if !ok {
+		return "", fmt.Errorf("bucket_by identifier %s not found", rule.BucketBy)
+	}
+	key, ok := bucketKey(obj)
+	if !ok {
+		return "", fmt.Errorf("bucket_by identifier %s is %s, want string or int", rule.BucketBy, obj.ObjectType())
+	}
+	b := Bucket(key, f.Salt, DefaultBuckets)
+	for _, w := range rule.Split {
+		if b < w.Weight {
+			return w.Variant, nil
+		}
+		b -= w.Weight
+	}
+	return rule.Split[len(rule.Split)-1].Variant, nil
+}
+
+func (f *Flag) result(variant, ruleID, reason string) FlagResult {
+	return FlagResult{Flag: f.Name, Variant: variant, Value: f.values[variant], RuleID: ruleID, Reason: reason}
+}
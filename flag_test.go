@@ -0,0 +1,111 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFlags = `{"flags": {
+	"new_checkout": {
+		"variants": {"on": true, "off": false},
+		"default": "off",
+		"rules": [
+			{"id": "staff", "when": "email == \"a@corp.com\"", "variant": "on"},
+			{"id": "rollout", "when": "country in [\"CN\", \"SG\"]", "bucket_by": "user_id",
+			 "split": [{"variant": "on", "weight": 50}, {"variant": "off", "weight": 50}]}
+		]
+	},
+	"checkout_theme": {
+		"variants": {"blue": "blue", "green": "green", "config": {"color": "red", "size": 3}, "limit": 10},
+		"default": "blue",
+		"rules": [
+			{"id": "vip", "when": "vip", "variant": "limit"},
+			{"id": "everyone", "variant": "config"}
+		]
+	},
+	"killed": {
+		"variants": {"on": true, "off": false},
+		"default": "off",
+		"disabled": true,
+		"rules": [{"variant": "on"}]
+	}
+}}`
+
+func TestEvaluateFlag(t *testing.T) {
+	schema := Schema{"email": STRING_OBJ, "country": STRING_OBJ, "user_id": STRING_OBJ, "vip": BOOLEAN_OBJ}
+	flags, err := LoadFlags([]byte(testFlags), schema)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"checkout_theme", "killed", "new_checkout"}, flags.Names())
+
+	user := func(email, country, id string, vip bool) *Environment {
+		env := NewEnvironment()
+		env.Set("email", &String{Value: email})
+		env.Set("country", &String{Value: country})
+		env.Set("user_id", &String{Value: id})
+		env.Set("vip", nativeBoolToBooleanObject(vip))
+		return env
+	}
+
+	result, err := flags.EvaluateFlag("new_checkout", user("a@corp.com", "US", "u1", false))
+	assert.Nil(t, err)
+	assert.Equal(t, FlagResult{Flag: "new_checkout", Variant: "on", Value: true, RuleID: "staff",
+		Reason: ReasonTargetingMatch}, result)
+
+	result, err = flags.EvaluateFlag("new_checkout", user("b@x.com", "US", "u1", false))
+	assert.Nil(t, err)
+	assert.Equal(t, FlagResult{Flag: "new_checkout", Variant: "off", Value: false, Reason: ReasonDefault}, result)
+
+	// 分桶的结果与bucket()一致
+	for _, id := range []string{"user-1", "user-2", "user-3", "user-4"} {
+		result, err = flags.EvaluateFlag("new_checkout", user("b@x.com", "CN", id, false))
+		assert.Nil(t, err)
+		assert.Equal(t, ReasonSplit, result.Reason)
+		assert.Equal(t, "rollout", result.RuleID)
+		assert.Equal(t, Bucket(id, "new_checkout", 100) < 50, result.Value, id)
+	}
+
+	result, _ = flags.EvaluateFlag("checkout_theme", user("", "", "", true))
+	assert.Equal(t, int64(10), result.Value)
+	result, _ = flags.EvaluateFlag("checkout_theme", user("", "", "", false))
+	assert.Equal(t, map[string]interface{}{"color": "red", "size": float64(3)}, result.Value)
+	assert.Equal(t, "everyone", result.RuleID)
+
+	result, _ = flags.EvaluateFlag("killed", user("", "", "", false))
+	assert.Equal(t, ReasonDisabled, result.Reason)
+
+	// 求值出错时返回默认变体
+	result, err = flags.EvaluateFlag("new_checkout", NewEnvironment())
+	assert.NotNil(t, err)
+	assert.Equal(t, FlagResult{Flag: "new_checkout", Variant: "off", Value: false, RuleID: "staff",
+		Reason: ReasonError}, result)
+
+	_, err = flags.EvaluateFlag("unknown", NewEnvironment())
+	assert.EqualError(t, err, "flag unknown not found")
+}
+
+func TestLoadFlagsValidation(t *testing.T) {
+	schema := Schema{"age": INTEGER_OBJ, "name": STRING_OBJ}
+	for _, tt := range []struct {
+		input string
+		err   string
+	}{
+		{`{"flags": {"f": {"variants": {"on": true}, "default": "off"}}}`,
+			`load flags: flag f: default variant "off" is not defined`},
+		{`{"flags": {"f": {"variants": {"on": true}, "default": "on", "rules": [{"id": "r", "when": "age > \"1\"", "variant": "on"}]}}}`,
+			`load flags: flag f: rule r: when "age > \"1\"": 1:7: InfixExpression <exp>><exp> right expect INTEGER, got STRING`},
+		{`{"flags": {"f": {"variants": {"on": true}, "default": "on", "rules": [{"id": "r", "when": "len(name)", "variant": "on"}]}}}`,
+			`load flags: flag f: rule r: when "len(name)" is INTEGER, want a boolean expression`},
+		{`{"flags": {"f": {"variants": {"on": true, "off": false}, "default": "on", "rules": [{"id": "r", "bucket_by": "age", "split": [{"variant": "on", "weight": 30}, {"variant": "x", "weight": 60}]}]}}}`,
+			`load flags: flag f: rule r: split variant "x" is not defined; flag f: rule r: split weights sum to 90, want 100`},
+		{`{"flags": {"f": {"variants": {"on": true}, "default": "on", "rules": [{"id": "r"}, {"id": "r", "variant": "on"}]}}}`,
+			`load flags: flag f: rule r: duplicate id; flag f: rule r: either variant or split is required`},
+		{`{"flags": {"f": {"variants": {"on": true}, "default": "on", "rule": []}}}`,
+			`load flags: json: unknown field "rule"`},
+	} {
+		_, err := LoadFlags([]byte(tt.input), schema)
+		assert.EqualError(t, err, tt.err)
+	}
+}
@@ -0,0 +1,158 @@
+package conditions
+
+import (
+	"bytes"
+	"strings"
+)
+
+// DefaultFormatWidth 格式化时单行的默认最大宽度
+const DefaultFormatWidth = 80
+
+// Format 将AST格式化为规范的、可以被重新解析的源码
+// 只在改变求值顺序时才添加括号，超过DefaultFormatWidth的布尔链会被拆成多行
+func Format(node Node) string {
+	return FormatWidth(node, DefaultFormatWidth)
+}
+
+// FormatWidth 同Format，width<=0时不进行换行
+func FormatWidth(node Node, width int) string {
+	f := &formatter{width: width}
+	switch n := node.(type) {
+	case *Program:
+		if n.Expression == nil {
+			return ""
+		}
+		f.expression(n.Expression, 0)
+	case Expression:
+		f.expression(n, 0)
+	}
+	return f.out.String()
+}
+
+type formatter struct {
+	out   bytes.Buffer
+	width int // 单行最大宽度
+}
+
+// expression 输出表达式，indent为当前的缩进层级
+func (f *formatter) expression(exp Expression, indent int) {
+	line := formatLine(exp)
+	if f.width <= 0 || len(line)+indent*4 <= f.width {
+		f.out.WriteString(line)
+		return
+	}
+	ie, ok := exp.(*InfixExpression)
+	if !ok || (ie.Operator != AND && ie.Operator != OR) {
+		f.out.WriteString(line)
+		return
+	}
+	// 拆分布尔链，每个操作数一行
+	operands := flattenChain(ie, ie.Operator)
+	prec := precedences[ie.Operator]
+	for i, operand := range operands {
+		if i != 0 {
+			f.out.WriteString(" " + string(ie.Operator) + "\n")
+			f.out.WriteString(strings.Repeat("\t", indent))
+		}
+		// 链中除第一个以外的操作数与运算符优先级相同时也需要括号，保持左结合
+		if expressionPrecedence(operand) < prec || (i != 0 && expressionPrecedence(operand) == prec) {
+			if line := "(" + formatLine(operand) + ")"; len(line)+indent*4 <= f.width {
+				f.out.WriteString(line)
+				continue
+			}
+			f.out.WriteString("(\n" + strings.Repeat("\t", indent+1))
+			f.expression(operand, indent+1)
+			f.out.WriteString("\n" + strings.Repeat("\t", indent) + ")")
+			continue
+		}
+		f.expression(operand, indent)
+	}
+}
+
+// flattenChain 将左结合的同一运算符链展开, ((a && b) && c) => [a, b, c]
+func flattenChain(exp Expression, operator TokenType) []Expression {
+	ie, ok := exp.(*InfixExpression)
+	if !ok || ie.Operator != operator {
+		return []Expression{exp}
+	}
+	return append(flattenChain(ie.Left, operator), ie.Right)
+}
+
+// formatLine 将表达式格式化为一行
+func formatLine(exp Expression) string {
+	switch n := exp.(type) {
+	case *String:
+		return quoteString(n.Value)
+	// 没有ip字面量，格式化为ip()调用
+	case *IP:
+		return "ip(" + quoteString(n.Value.String()) + ")"
+	case *Array:
+		items := make([]string, 0, len(n.Elements))
+		for _, item := range n.Elements {
+			items = append(items, formatLine(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *PrefixExpresion:
+		return string(n.Operator) + formatOperand(n.Right, PREFIX, false)
+	case *InfixExpression:
+		prec := precedences[n.Operator]
+		return formatOperand(n.Left, prec, false) + " " + string(n.Operator) + " " +
+			formatOperand(n.Right, prec, true)
+	case *CallExpression:
+		args := make([]string, 0, len(n.Arguments))
+		for _, a := range n.Arguments {
+			args = append(args, formatLine(a))
+		}
+		return formatOperand(n.Function, CALL, false) + "(" + strings.Join(args, ", ") + ")"
+	case nil:
+		return ""
+	}
+	return exp.String()
+}
+
+// formatOperand 格式化子表达式，只在优先级需要时添加括号
+// 解析器是左结合的，右侧子表达式优先级相同时也需要括号
+func formatOperand(exp Expression, parent int, right bool) string {
+	s := formatLine(exp)
+	prec := expressionPrecedence(exp)
+	if prec < parent || (right && prec == parent) {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// expressionPrecedence 返回表达式顶层运算符的优先级
+func expressionPrecedence(exp Expression) int {
+	switch n := exp.(type) {
+	case *InfixExpression:
+		if p, ok := precedences[n.Operator]; ok {
+			return p
+		}
+		return LOWEST
+	case *PrefixExpresion:
+		return PREFIX
+	case *CallExpression:
+		return CALL
+	}
+	// 字面量和标识符不需要括号
+	return CALL + 1
+}
+
+// quoteString 在两侧加上引号并转义引号，只转义会被词法解析器当作转义的反斜杠，
+// 正则中的 \d 等保持不变
+func quoteString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			out.WriteString(`\"`)
+		case s[i] == '\\' && (i+1 == len(s) || s[i+1] == '"' || s[i+1] == '\\'):
+			out.WriteString(`\\`)
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
@@ -0,0 +1,100 @@
+package conditions
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(len(abc) > 1 && X == "123") || Y in [1, 2, 3]`, `len(abc) > 1 && X == "123" || Y in [1, 2, 3]`},
+		{`a && (b || c)`, `a && (b || c)`},
+		{`!(a > 1) && !b`, `!(a > 1) && !b`},
+		{`((a == b)) != (c == d)`, `a == b != (c == d)`},
+		{`name in ["x", "y"]`, `name in ["x", "y"]`},
+		{`regexp(name, "^a") && len([1,2]) >= 2`, `regexp(name, "^a") && len([1, 2]) >= 2`},
+	}
+	for _, tt := range tests {
+		program := NewParser(NewLexer(tt.input)).ParseProgram()
+		assert.Equal(t, tt.expected, Format(program), tt.input)
+		assertRoundTrip(t, program, DefaultFormatWidth)
+	}
+}
+
+func TestFormatLineBreaking(t *testing.T) {
+	input := `country in ["CN", "SG", "MY"] && age >= 18 && (vip == true || score > 1000) && len(nickname) > 0`
+	program := NewParser(NewLexer(input)).ParseProgram()
+	out := FormatWidth(program, 40)
+	assert.Equal(t, strings.Join([]string{
+		`country in ["CN", "SG", "MY"] &&`,
+		`age >= 18 &&`,
+		`(vip == true || score > 1000) &&`,
+		`len(nickname) > 0`,
+	}, "\n"), out)
+	assertRoundTrip(t, program, 40)
+	assertRoundTrip(t, program, 10)
+}
+
+// assertRoundTrip 校验 parse(format(x)) == x
+func assertRoundTrip(t *testing.T, program *Program, width int) {
+	out := FormatWidth(program, width)
+	p := NewParser(NewLexer(out))
+	reparsed := p.ParseProgram()
+	assert.Equal(t, program, reparsed, out)
+}
+
+func TestFormatNegativeLiterals(t *testing.T) {
+	a, f, d := &Identifier{Value: "a"}, &Identifier{Value: "f"}, &Identifier{Value: "d"}
+	infix := func(left Expression, op TokenType, right Expression) *InfixExpression {
+		return &InfixExpression{Left: left, Operator: op, Right: right}
+	}
+	env := NewEnvironment()
+	env.Set("a", &Integer{Value: -5})
+	env.Set("f", &Float{Value: -1})
+	env.Set("d", &Duration{Value: -7*24*time.Hour - time.Nanosecond})
+	tests := []struct {
+		exp      Expression
+		expected string
+	}{
+		{infix(infix(a, MINUS, &Integer{Value: -5}), EQ, &Integer{Value: 0}), `a - -5 == 0`},
+		{infix(&Integer{Value: math.MinInt64}, LT, a), `-9223372036854775808 < a`},
+		{infix(f, GT, &Float{Value: -1.5}), `f > -1.5`},
+		{infix(d, EQ, &Duration{Value: -7*24*time.Hour - time.Nanosecond}), `d == -7d1ns`},
+		{infix(a, IN, NewIntegerArray(-5, 1)), `a in [-5, 1]`},
+	}
+	for _, tt := range tests {
+		program := &Program{Expression: tt.exp}
+		out := Format(program)
+		assert.Equal(t, tt.expected, out)
+		p := NewParser(NewLexer(out))
+		reparsed := p.ParseProgram()
+		if assert.Empty(t, p.Errors(), out) {
+			assert.Equal(t, boolTrue, Eval(program, env), out)
+			assert.Equal(t, boolTrue, Eval(reparsed, env), out)
+		}
+		assertRoundTrip(t, program, DefaultFormatWidth)
+	}
+}
+
+func TestFormatQuotes(t *testing.T) {
+	for value, expected := range map[string]string{
+		`say "hi"`: `name == "say \"hi\""`,
+		`^\d+\.`:   `name == "^\d+\."`,
+		`a\`:       `name == "a\\"`,
+		`a\\b`:     `name == "a\\\b"`,
+		`\"`:       `name == "\\\""`,
+	} {
+		program := &Program{Expression: &InfixExpression{
+			Left: &Identifier{Value: "name"}, Operator: EQ, Right: &String{Value: value}}}
+		out := Format(program)
+		assert.Equal(t, expected, out, value)
+		assertRoundTrip(t, program, DefaultFormatWidth)
+	}
+}
@@ -0,0 +1,178 @@
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// RuleIndex 用于大量规则匹配同一个事件的场景
+//
+// 添加规则时分析顶层 && 链中的 ident == 字面量 和 ident in [...] 条件，建立倒排索引；
+// 匹配时只有通过索引筛选出的规则和无法索引的规则才会被完整求值，结果与逐条求值相同
+type RuleIndex struct {
+	rules     map[string]*Program
+	index     map[string]map[string][]string // 标识符 => 值 => 规则ID
+	unindexed []string                       // 无法建立索引的规则ID
+}
+
+// NewRuleIndex
+func NewRuleIndex() *RuleIndex {
+	return &RuleIndex{
+		rules: make(map[string]*Program),
+		index: make(map[string]map[string][]string),
+	}
+}
+
+// Add 添加一条规则，id不能重复
+func (ri *RuleIndex) Add(id string, program *Program) error {
+	if _, ok := ri.rules[id]; ok {
+		return fmt.Errorf("duplicate rule id %q", id)
+	}
+	ri.rules[id] = program
+	ident, keys := indexPredicate(program.Expression)
+	if ident == "" {
+		ri.unindexed = append(ri.unindexed, id)
+		return nil
+	}
+	values, ok := ri.index[ident]
+	if !ok {
+		values = make(map[string][]string)
+		ri.index[ident] = values
+	}
+	for _, key := range keys {
+		values[key] = append(values[key], id)
+	}
+	return nil
+}
+
+// Len 返回规则的数量
+func (ri *RuleIndex) Len() int {
+	return len(ri.rules)
+}
+
+// Match 返回在env下结果为true的规则ID，已排序
+func (ri *RuleIndex) Match(env *Environment) []string {
+	candidates := make(map[string]struct{}, len(ri.unindexed))
+	for _, id := range ri.unindexed {
+		candidates[id] = struct{}{}
+	}
+	for ident, values := range ri.index {
+		val, ok := env.Get(ident)
+		if !ok {
+			continue
+		}
+		key, ok := indexKey(val)
+		if !ok {
+			// 没有索引键的值(例如ip)仍然可能满足条件，ip in ["10.0.0.1"]，不能剪枝
+			for _, ids := range values {
+				for _, id := range ids {
+					candidates[id] = struct{}{}
+				}
+			}
+			continue
+		}
+		for _, id := range values[key] {
+			candidates[id] = struct{}{}
+		}
+	}
+
+	var matched []string
+	for id := range candidates {
+		if ruleMatches(ri.rules[id], env) {
+			matched = append(matched, id)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// ruleMatches 规则的结果为true时匹配，求值出错视为不匹配
+func ruleMatches(program *Program, env *Environment) bool {
+	b, ok := Eval(program, env).(*Boolean)
+	return ok && b.Value
+}
+
+// indexPredicate 在顶层的 && 链中选择一个可以建立索引的条件
+// 返回标识符和匹配的值，值越少的条件筛选效果越好
+func indexPredicate(exp Expression) (string, []string) {
+	bestIdent, bestKeys := "", []string(nil)
+	for _, operand := range flattenChain(exp, AND) {
+		ident, keys := equalityPredicate(operand)
+		if ident == "" {
+			continue
+		}
+		if bestIdent == "" || len(keys) < len(bestKeys) {
+			bestIdent, bestKeys = ident, keys
+		}
+	}
+	return bestIdent, bestKeys
+}
+
+// equalityPredicate 解析 ident == 字面量, 字面量 == ident, ident in [...]
+func equalityPredicate(exp Expression) (string, []string) {
+	ie, ok := exp.(*InfixExpression)
+	if !ok {
+		return "", nil
+	}
+	switch ie.Operator {
+	case EQ:
+		left, right := ie.Left, ie.Right
+		if _, ok := left.(*Identifier); !ok {
+			left, right = right, left
+		}
+		ident, ok := left.(*Identifier)
+		if !ok {
+			return "", nil
+		}
+		key, ok := indexKey(right)
+		if !ok {
+			return "", nil
+		}
+		return ident.Value, []string{key}
+	case IN:
+		ident, ok := ie.Left.(*Identifier)
+		if !ok {
+			return "", nil
+		}
+		arr, ok := ie.Right.(*Array)
+		if !ok {
+			return "", nil
+		}
+		// CIDR列表按照网段匹配，不能按值建立索引
+		if _, ok := cidrList(arr); ok {
+			return "", nil
+		}
+		var keys []string
+		for _, elem := range arr.Elements {
+			key, ok := indexKey(elem)
+			if !ok {
+				return "", nil
+			}
+			keys = append(keys, key)
+		}
+		return ident.Value, keys
+	}
+	return "", nil
+}
+
+// indexKey 值在索引中的键，带有类型前缀，保证不同类型的值不会相等
+// 整数值的浮点数与整数使用相同的键，与求值时按数值比较一致
+func indexKey(obj interface{}) (string, bool) {
+	switch v := obj.(type) {
+	case *Integer:
+		return integerKey(v.Value), true
+	case *Float:
+		if v.Value == math.Trunc(v.Value) && v.Value >= math.MinInt64 && v.Value < math.MaxInt64 {
+			return integerKey(int64(v.Value)), true
+		}
+		return "f:" + strconv.FormatFloat(v.Value, 'g', -1, 64), true
+	case *String:
+		return stringKey(v.Value), true
+	}
+	return "", false
+}
+
+func integerKey(v int64) string { return fmt.Sprintf("i:%d", v) }
+func stringKey(v string) string { return "s:" + v }
@@ -0,0 +1,92 @@
+package conditions
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomExpression 随机生成只包含a,b,c三个标识符的表达式
+func randomExpression(r *rand.Rand, depth int) string {
+	idents := []string{"a", "b", "c"}
+	ident := idents[r.Intn(len(idents))]
+	if depth <= 0 || r.Intn(3) == 0 {
+		switch r.Intn(8) {
+		case 0:
+			return fmt.Sprintf("%s == %d", ident, r.Intn(4))
+		case 1:
+			return fmt.Sprintf("%s == \"%d\"", ident, r.Intn(4))
+		case 2:
+			return fmt.Sprintf("%s in [%d, %d]", ident, r.Intn(4), r.Intn(4))
+		case 3:
+			return fmt.Sprintf("%s in [\"%d\"]", ident, r.Intn(4))
+		case 4:
+			return fmt.Sprintf("%s == %s", ident, randomFloat(r))
+		case 5:
+			return fmt.Sprintf("%s in [%s, %d]", ident, randomFloat(r), r.Intn(4))
+		case 6:
+			return fmt.Sprintf("%s in [\"10.0.0.%d\", \"10.0.0.%d\"]", ident, r.Intn(4), r.Intn(4))
+		default:
+			return fmt.Sprintf("%s > %d", ident, r.Intn(4))
+		}
+	}
+	left, right := randomExpression(r, depth-1), randomExpression(r, depth-1)
+	switch r.Intn(3) {
+	case 0:
+		return fmt.Sprintf("(%s) && (%s)", left, right)
+	case 1:
+		return fmt.Sprintf("(%s) || (%s)", left, right)
+	default:
+		return fmt.Sprintf("!(%s) && %s", left, right)
+	}
+}
+
+// randomFloat 随机生成整数值或者带小数的浮点数字面量
+func randomFloat(r *rand.Rand) string {
+	return fmt.Sprintf("%d.%d", r.Intn(4), 5*r.Intn(2))
+}
+
+func TestRuleIndexMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	index := NewRuleIndex()
+	rules := map[string]*Program{}
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("rule-%d", i)
+		p := NewParser(NewLexer(randomExpression(r, 3)))
+		program := p.ParseProgram()
+		if !assert.Empty(t, p.Errors(), program.String()) {
+			return
+		}
+		rules[id] = program
+		assert.Nil(t, index.Add(id, program))
+	}
+	assert.NotNil(t, index.Add("rule-0", rules["rule-0"]))
+
+	for i := 0; i < 200; i++ {
+		env := NewEnvironment()
+		for _, ident := range []string{"a", "b", "c"} {
+			switch r.Intn(5) {
+			case 0:
+				env.Set(ident, &Integer{Value: int64(r.Intn(4))})
+			case 1:
+				env.Set(ident, &String{Value: fmt.Sprintf("%d", r.Intn(4))})
+			case 2:
+				env.Set(ident, &Float{Value: float64(r.Intn(8)) / 2})
+			case 3:
+				env.Set(ident, &IP{Value: net.ParseIP(fmt.Sprintf("10.0.0.%d", r.Intn(4)))})
+			}
+		}
+		var expected []string
+		for id, program := range rules {
+			if ruleMatches(program, env) {
+				expected = append(expected, id)
+			}
+		}
+		sort.Strings(expected)
+		assert.Equal(t, expected, index.Match(env))
+	}
+}
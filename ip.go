@@ -0,0 +1,249 @@
+package conditions
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IP ip地址，支持v4和v6，由ip()函数或者绑定net.IP得到，可以作为数组的元素
+type IP struct {
+	Value net.IP
+}
+
+func (i *IP) node()                  {}
+func (i *IP) expressionNode()        {}
+func (i *IP) ObjectType() ObjectType { return IP_OBJ }
+func (i *IP) String() string         { return i.Value.String() }
+
+// 私有地址段，RFC 1918和RFC 4193，不包括环回地址和链路本地地址
+var privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	ret := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+// parseIP 解析ip地址，v4地址统一使用4字节的形式
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address %q", s)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4, nil
+	}
+	return ip, nil
+}
+
+// parseNetwork 解析CIDR，单个ip地址视为只包含自己的网段
+func parseNetwork(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip, err := parseIP(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q", s)
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+}
+
+// matchNetworks 判断ip是否属于任意一个CIDR
+func matchNetworks(ip net.IP, cidrs []string) (bool, error) {
+	for _, cidr := range cidrs {
+		n, err := parseNetwork(cidr)
+		if err != nil {
+			return false, err
+		}
+		if n.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cidrList 数组中都是CIDR或者ip地址，并且至少有一个CIDR时返回这些字符串
+func cidrList(arr *Array) ([]string, bool) {
+	cidrs, ok := arr.Strings()
+	if !ok {
+		return nil, false
+	}
+	hasCIDR := false
+	for _, cidr := range cidrs {
+		if _, err := parseNetwork(cidr); err != nil {
+			return nil, false
+		}
+		hasCIDR = hasCIDR || strings.Contains(cidr, "/")
+	}
+	return cidrs, hasCIDR
+}
+
+// stringInNetworks 字符串形式的ip in CIDR列表，JSON和命令行中的ip只能绑定为字符串
+// s不是ip地址或者数组不是CIDR列表时返回false，按照字符串比较
+func stringInNetworks(s string, arr *Array) (bool, bool) {
+	cidrs, ok := cidrList(arr)
+	if !ok {
+		return false, false
+	}
+	ip, err := parseIP(s)
+	if err != nil {
+		return false, false
+	}
+	matched, err := matchNetworks(ip, cidrs)
+	return matched, err == nil
+}
+
+// ipArg 将IP或者字符串参数转换为net.IP
+func ipArg(name string, arg Object) (net.IP, *Error) {
+	switch arg := arg.(type) {
+	case *IP:
+		return arg.Value, nil
+	case *String:
+		ip, err := parseIP(arg.Value)
+		if err != nil {
+			return nil, newError("%s: %s", name, err)
+		}
+		return ip, nil
+	}
+	return nil, newError("argument to `%s` not supported, got %s", name, arg.ObjectType())
+}
+
+// evalIPInfixExpression 执行ip地址的比较以及 ip in ["10.0.0.0/8", "192.168.1.1"]，
+// 数组不是字符串数组时按照元素相等判断，例如 ip in [a, b]
+func evalIPInfixExpression(operator TokenType, left, right Object) Object {
+	ip := left.(*IP).Value
+	switch r := right.(type) {
+	case *IP:
+		switch operator {
+		case EQ:
+			return nativeBoolToBooleanObject(ip.Equal(r.Value))
+		case NOT_EQ:
+			return nativeBoolToBooleanObject(!ip.Equal(r.Value))
+		}
+	case *Array:
+		if cidrs, ok := r.Strings(); ok && operator == IN {
+			matched, err := matchNetworks(ip, cidrs)
+			if err != nil {
+				return newError("%s", err)
+			}
+			return nativeBoolToBooleanObject(matched)
+		}
+		if operator == IN {
+			return evalINInfixExpress(left, right)
+		}
+	}
+	return newError("unknow operator: %s %s %s", left.ObjectType(), operator, right.ObjectType())
+}
+
+// checkIPLiterals 类型检测时校验常量形式的ip地址和CIDR
+func checkIPLiterals(name string, args []Expression) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if s, ok := args[0].(*String); ok {
+		if _, err := parseIP(s.Value); err != nil {
+			return err
+		}
+	}
+	if name != "cidr_match" || len(args) != 2 {
+		return nil
+	}
+	return checkCIDRLiterals(args[1])
+}
+
+// checkCIDRLiterals 校验CIDR字面量或者CIDR数组字面量
+func checkCIDRLiterals(exp Expression) error {
+	var cidrs []string
+	switch n := exp.(type) {
+	case *String:
+		cidrs = []string{n.Value}
+	case *Array:
+		// 只校验字符串字面量
+		for _, elem := range n.Elements {
+			if s, ok := elem.(*String); ok {
+				cidrs = append(cidrs, s.Value)
+			}
+		}
+	}
+	for _, cidr := range cidrs {
+		if _, err := parseNetwork(cidr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("ip", func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of argument. got=%d, want=1", len(args))
+		}
+		ip, err := ipArg("ip", args[0])
+		if err != nil {
+			return err
+		}
+		return &IP{Value: ip}
+	})
+	// cidr_match(ip, "10.0.0.0/8") 或者 cidr_match(ip, ["10.0.0.0/8", "fc00::/7"])
+	RegisterBuiltin("cidr_match", func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of argument. got=%d, want=2", len(args))
+		}
+		ip, err := ipArg("cidr_match", args[0])
+		if err != nil {
+			return err
+		}
+		var cidrs []string
+		switch arg := args[1].(type) {
+		case *String:
+			cidrs = []string{arg.Value}
+		case *Array:
+			var ok bool
+			if cidrs, ok = arg.Strings(); !ok {
+				return newError("second argument to `cidr_match` must be a list of strings, got %s", arg.ObjectType())
+			}
+		default:
+			return newError("second argument to `cidr_match` not supported, got %s", args[1].ObjectType())
+		}
+		matched, e := matchNetworks(ip, cidrs)
+		if e != nil {
+			return newError("cidr_match: %s", e)
+		}
+		return nativeBoolToBooleanObject(matched)
+	})
+	RegisterBuiltin("is_private", func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of argument. got=%d, want=1", len(args))
+		}
+		ip, err := ipArg("is_private", args[0])
+		if err != nil {
+			return err
+		}
+		for _, n := range privateNetworks {
+			if n.Contains(ip) {
+				return boolTrue
+			}
+		}
+		return boolFalse
+	})
+	RegisterBuiltin("ip_version", func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of argument. got=%d, want=1", len(args))
+		}
+		ip, err := ipArg("ip_version", args[0])
+		if err != nil {
+			return err
+		}
+		if ip.To4() != nil {
+			return &Integer{Value: 4}
+		}
+		return &Integer{Value: 6}
+	})
+}
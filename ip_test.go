@@ -0,0 +1,131 @@
+package conditions
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIP(t *testing.T) {
+	env := NewEnvironment()
+	assert.Nil(t, Bind(env, map[string]interface{}{
+		"client_ip": net.ParseIP("10.1.2.3"),
+		"ipv6":      net.ParseIP("2001:db8::1"),
+		"addr":      "192.168.1.20",
+	}))
+
+	tests := []struct {
+		input  string
+		expect Object
+	}{
+		{`cidr_match(client_ip, "10.0.0.0/8")`, boolTrue},
+		{`cidr_match(addr, "10.0.0.0/8")`, boolFalse},
+		{`cidr_match(addr, ["10.0.0.0/8", "192.168.0.0/16"])`, boolTrue},
+		{`cidr_match(ipv6, "2001:db8::/32")`, boolTrue},
+		{`client_ip in ["172.16.0.0/12", "10.1.2.3"]`, boolTrue},
+		{`ipv6 in ["10.0.0.0/8"]`, boolFalse},
+		{`is_private(client_ip) && is_private(addr)`, boolTrue},
+		{`is_private("8.8.8.8") || is_private(ipv6)`, boolFalse},
+		{`is_private("fd12:3456::1")`, boolTrue},
+		{`ip_version(client_ip)`, &Integer{Value: 4}},
+		{`ip_version("::1")`, &Integer{Value: 6}},
+		{`ip(addr) == ip("192.168.1.20")`, boolTrue},
+		{`client_ip != ip("10.1.2.4")`, boolTrue},
+		// 字符串形式的ip in CIDR列表按照网段匹配
+		{`addr in ["192.168.0.0/16"]`, boolTrue},
+		{`addr in ["10.0.0.0/8", "8.8.8.8"]`, boolFalse},
+		{`addr in ["192.168.1.20"]`, boolTrue},
+		{`"not an ip" in ["10.0.0.0/8"]`, boolFalse},
+		// 环回地址和链路本地地址不是私有地址
+		{`is_private("127.0.0.1") || is_private("169.254.1.1") || is_private("fe80::1") || is_private("::1")`, boolFalse},
+	}
+	for _, tt := range tests {
+		program, err := Compile(tt.input)
+		if !assert.Nil(t, err, tt.input) {
+			continue
+		}
+		assert.Equal(t, tt.expect, Eval(program, env), tt.input)
+	}
+
+	obj := Eval(NewParser(NewLexer(`is_private(addr)`)).ParseProgram(), func() *Environment {
+		env := NewEnvironment()
+		env.Set("addr", &String{Value: "not an ip"})
+		return env
+	}())
+	assert.EqualError(t, obj.(*Error), `is_private: invalid ip address "not an ip"`)
+}
+
+func TestIPTypeCheck(t *testing.T) {
+	schema := Schema{"client_ip": IP_OBJ, "other_ip": IP_OBJ, "addr": STRING_OBJ}
+	for input, ok := range map[string]bool{
+		`cidr_match(client_ip, "10.0.0.0/8")`:        true,
+		`client_ip in ["10.0.0.0/8", "::1"]`:         true,
+		`ip_version(addr) == 4`:                      true,
+		`cidr_match(client_ip, "10.0.0.0/33")`:       false,
+		`cidr_match(addr, ["10.0.0.0/8", "10.0/8"])`: false,
+		`client_ip in ["10.0.0.0/8", "localhost"]`:   false,
+		`is_private("300.1.1.1")`:                    false,
+		`ip_version(1) == 4`:                         false,
+		`client_ip == "10.0.0.1"`:                    false,
+		`client_ip in [1, 2]`:                        false,
+		`client_ip in [client_ip, other_ip]`:         true,
+		`client_ip in [client_ip, addr]`:             false,
+	} {
+		p := NewParser(NewLexer(input))
+		p.SetSchema(schema)
+		p.ParseProgram()
+		assert.Equal(t, ok, len(p.Errors()) == 0, "%s: %v", input, p.Errors())
+	}
+}
+
+func TestIPArray(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("a", &IP{Value: net.ParseIP("10.0.0.1").To4()})
+	env.Set("b", &IP{Value: net.ParseIP("2001:db8::1")})
+	env.Set("c", &IP{Value: net.ParseIP("10.0.0.2").To4()})
+
+	for input, expect := range map[string]Object{
+		`a in [a, b]`:              boolTrue,
+		`c in [a, b]`:              boolFalse,
+		`a in [ip("10.0.0.1")]`:    boolTrue,
+		`b in [a, ip("::1")]`:      boolFalse,
+		`ip("2001:db8::1") in [b]`: boolTrue,
+	} {
+		assert.Equal(t, expect, Eval(mustCompile(t, input), env), input)
+	}
+
+	// 部分求值后ip作为数组元素，可以格式化、序列化后重新求值
+	program, err := PartialEval(mustCompile(t, `x in [a, b]`), env)
+	assert.Nil(t, err)
+	assert.Equal(t, `x in [ip("10.0.0.1"), ip("2001:db8::1")]`, Format(program))
+	data, err := json.Marshal(program)
+	assert.Nil(t, err)
+	decoded, err := ParseJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, program, decoded)
+
+	env.Set("x", &IP{Value: net.ParseIP("2001:db8::1")})
+	assert.Equal(t, boolTrue, Eval(decoded, env))
+	assert.Equal(t, boolTrue, Eval(mustCompile(t, Format(program)), env))
+}
+
+func TestStringInCIDRs(t *testing.T) {
+	program := mustCompile(t, `addr in ["10.0.0.0/8", "192.168.0.0/16"]`)
+	env := NewEnvironment()
+	env.Set("addr", &String{Value: "10.1.2.3"})
+
+	// 规则索引、可满足性分析和翻译不能把CIDR列表当作字符串集合
+	index := NewRuleIndex()
+	assert.Nil(t, index.Add("cidr", program))
+	assert.Equal(t, []string{"cidr"}, index.Match(env))
+	_, _, err := Satisfiable(program, Schema{"addr": STRING_OBJ})
+	assert.NotNil(t, err)
+	_, _, err = ToSQL(program, MySQL)
+	assert.EqualError(t, err, `cannot translate to sql: (addr in ["10.0.0.0/8","192.168.0.0/16"]) matches CIDRs, which sql does not support`)
+	_, err = ToMongo(program)
+	assert.NotNil(t, err)
+	_, err = ToElastic(program, nil)
+	assert.NotNil(t, err)
+}
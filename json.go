@@ -0,0 +1,371 @@
+package conditions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ASTVersion AST JSON格式的版本号，格式发生不兼容的变化时递增
+// 版本2中数组统一为array节点，仍然可以读取版本1的array_integer和array_string
+const ASTVersion = 2
+
+// AST节点在JSON中的类型标记
+const (
+	kindIdentifier   = "identifier"
+	kindInteger      = "integer"
+	kindString       = "string"
+	kindBoolean      = "boolean"
+	kindFloat        = "float"
+	kindArray        = "array"
+	kindArrayInteger = "array_integer" // 版本1
+	kindArrayString  = "array_string"  // 版本1
+	kindTime         = "time"
+	kindDuration     = "duration"
+	kindIP           = "ip"
+	kindPrefix       = "prefix"
+	kindInfix        = "infix"
+	kindCall         = "call"
+)
+
+// jsonNode 所有AST节点共用的JSON结构，通过Kind区分节点类型
+type jsonNode struct {
+	Kind      string            `json:"kind"`
+	Value     json.RawMessage   `json:"value,omitempty"`
+	Operator  TokenType         `json:"operator,omitempty"`
+	Left      json.RawMessage   `json:"left,omitempty"`
+	Right     json.RawMessage   `json:"right,omitempty"`
+	Function  json.RawMessage   `json:"function,omitempty"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+	Elements  []json.RawMessage `json:"elements,omitempty"`
+}
+
+// jsonProgram 空的Program没有expression字段
+type jsonProgram struct {
+	Version    int             `json:"version"`
+	Expression json.RawMessage `json:"expression,omitempty"`
+}
+
+// ParseJSON 从JSON中加载Program并进行类型检测
+func ParseJSON(data []byte) (*Program, error) {
+	program := &Program{}
+	if err := json.Unmarshal(data, program); err != nil {
+		return nil, err
+	}
+	p := &Parser{}
+	p.CheckType(program)
+	if len(p.errors) != 0 {
+		return nil, fmt.Errorf("type check: %s", strings.Join(p.errors, "; "))
+	}
+	return program, nil
+}
+
+func (p *Program) MarshalJSON() ([]byte, error) {
+	if p.Expression == nil {
+		return json.Marshal(jsonProgram{Version: ASTVersion})
+	}
+	exp, err := json.Marshal(p.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonProgram{Version: ASTVersion, Expression: exp})
+}
+
+func (p *Program) UnmarshalJSON(data []byte) error {
+	var jp jsonProgram
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	if jp.Version < 1 || jp.Version > ASTVersion {
+		return fmt.Errorf("unsupported ast version %d, want <= %d", jp.Version, ASTVersion)
+	}
+	if len(jp.Expression) == 0 || string(jp.Expression) == "null" {
+		p.Expression = nil
+		return nil
+	}
+	exp, err := unmarshalExpression(jp.Expression)
+	if err != nil {
+		return err
+	}
+	p.Expression = exp
+	return nil
+}
+
+func (i *Identifier) MarshalJSON() ([]byte, error) { return marshalValue(kindIdentifier, i.Value) }
+func (i *Identifier) UnmarshalJSON(data []byte) error {
+	return unmarshalValue(data, kindIdentifier, &i.Value)
+}
+
+func (il *Integer) MarshalJSON() ([]byte, error) { return marshalValue(kindInteger, il.Value) }
+func (il *Integer) UnmarshalJSON(data []byte) error {
+	return unmarshalValue(data, kindInteger, &il.Value)
+}
+
+func (s *String) MarshalJSON() ([]byte, error) { return marshalValue(kindString, s.Value) }
+func (s *String) UnmarshalJSON(data []byte) error {
+	return unmarshalValue(data, kindString, &s.Value)
+}
+
+func (b *Boolean) MarshalJSON() ([]byte, error) { return marshalValue(kindBoolean, b.Value) }
+func (b *Boolean) UnmarshalJSON(data []byte) error {
+	return unmarshalValue(data, kindBoolean, &b.Value)
+}
+
+func (f *Float) MarshalJSON() ([]byte, error) { return marshalValue(kindFloat, f.Value) }
+func (f *Float) UnmarshalJSON(data []byte) error {
+	return unmarshalValue(data, kindFloat, &f.Value)
+}
+
+func (a *Array) MarshalJSON() ([]byte, error) {
+	elems := make([]json.RawMessage, 0, len(a.Elements))
+	for _, e := range a.Elements {
+		elem, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return json.Marshal(jsonNode{Kind: kindArray, Elements: elems})
+}
+
+func (a *Array) UnmarshalJSON(data []byte) error {
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return err
+	}
+	// 版本1的整数数组和字符串数组
+	switch kind.Kind {
+	case kindArrayInteger:
+		var vals []int64
+		if err := unmarshalValue(data, kindArrayInteger, &vals); err != nil {
+			return err
+		}
+		*a = *NewIntegerArray(vals...)
+		return nil
+	case kindArrayString:
+		var vals []string
+		if err := unmarshalValue(data, kindArrayString, &vals); err != nil {
+			return err
+		}
+		*a = *NewStringArray(vals...)
+		return nil
+	}
+	n, err := decodeNode(data, kindArray)
+	if err != nil {
+		return err
+	}
+	elems := make([]Expression, 0, len(n.Elements))
+	for _, e := range n.Elements {
+		elem, err := unmarshalExpression(e)
+		if err != nil {
+			return err
+		}
+		elems = append(elems, elem)
+	}
+	*a = *NewArray(elems...)
+	return nil
+}
+
+// 时间使用RFC3339格式，时间间隔使用字面量的格式, "7d"，不足1毫秒的部分使用us和ns
+func (t *Time) MarshalJSON() ([]byte, error) { return marshalValue(kindTime, t.Value) }
+func (t *Time) UnmarshalJSON(data []byte) error {
+	return unmarshalValue(data, kindTime, &t.Value)
+}
+
+func (d *Duration) MarshalJSON() ([]byte, error) {
+	return marshalValue(kindDuration, formatDuration(d.Value))
+}
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := unmarshalValue(data, kindDuration, &s); err != nil {
+		return err
+	}
+	v, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Value = v
+	return nil
+}
+
+func (i *IP) MarshalJSON() ([]byte, error) {
+	return marshalValue(kindIP, i.Value.String())
+}
+func (i *IP) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := unmarshalValue(data, kindIP, &s); err != nil {
+		return err
+	}
+	ip, err := parseIP(s)
+	if err != nil {
+		return err
+	}
+	i.Value = ip
+	return nil
+}
+
+func (pe *PrefixExpresion) MarshalJSON() ([]byte, error) {
+	right, err := json.Marshal(pe.Right)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonNode{Kind: kindPrefix, Operator: pe.Operator, Right: right})
+}
+
+func (pe *PrefixExpresion) UnmarshalJSON(data []byte) error {
+	n, err := decodeNode(data, kindPrefix)
+	if err != nil {
+		return err
+	}
+	if _, ok := prefixProtos[n.Operator]; !ok {
+		return fmt.Errorf("unknow prefix operator(%s)", n.Operator)
+	}
+	if pe.Right, err = unmarshalExpression(n.Right); err != nil {
+		return err
+	}
+	pe.Operator = n.Operator
+	return nil
+}
+
+func (ie *InfixExpression) MarshalJSON() ([]byte, error) {
+	left, err := json.Marshal(ie.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := json.Marshal(ie.Right)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonNode{Kind: kindInfix, Operator: ie.Operator, Left: left, Right: right})
+}
+
+func (ie *InfixExpression) UnmarshalJSON(data []byte) error {
+	n, err := decodeNode(data, kindInfix)
+	if err != nil {
+		return err
+	}
+	if _, ok := precedences[n.Operator]; !ok || n.Operator == LPAREN {
+		return fmt.Errorf("unknow infix operator(%s)", n.Operator)
+	}
+	if ie.Left, err = unmarshalExpression(n.Left); err != nil {
+		return err
+	}
+	if ie.Right, err = unmarshalExpression(n.Right); err != nil {
+		return err
+	}
+	ie.Operator = n.Operator
+	return nil
+}
+
+func (ce *CallExpression) MarshalJSON() ([]byte, error) {
+	function, err := json.Marshal(ce.Function)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]json.RawMessage, 0, len(ce.Arguments))
+	for _, a := range ce.Arguments {
+		arg, err := json.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return json.Marshal(jsonNode{Kind: kindCall, Function: function, Arguments: args})
+}
+
+func (ce *CallExpression) UnmarshalJSON(data []byte) error {
+	n, err := decodeNode(data, kindCall)
+	if err != nil {
+		return err
+	}
+	if ce.Function, err = unmarshalExpression(n.Function); err != nil {
+		return err
+	}
+	ce.Arguments = make([]Expression, 0, len(n.Arguments))
+	for _, a := range n.Arguments {
+		arg, err := unmarshalExpression(a)
+		if err != nil {
+			return err
+		}
+		ce.Arguments = append(ce.Arguments, arg)
+	}
+	return nil
+}
+
+// unmarshalExpression 根据kind标记解析任意的表达式节点
+func unmarshalExpression(data []byte) (Expression, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("missing expression")
+	}
+	var n struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	var exp Expression
+	switch n.Kind {
+	case kindIdentifier:
+		exp = &Identifier{}
+	case kindInteger:
+		exp = &Integer{}
+	case kindString:
+		exp = &String{}
+	case kindBoolean:
+		exp = &Boolean{}
+	case kindFloat:
+		exp = &Float{}
+	case kindArray, kindArrayInteger, kindArrayString:
+		exp = &Array{}
+	case kindTime:
+		exp = &Time{}
+	case kindDuration:
+		exp = &Duration{}
+	case kindIP:
+		exp = &IP{}
+	case kindPrefix:
+		exp = &PrefixExpresion{}
+	case kindInfix:
+		exp = &InfixExpression{}
+	case kindCall:
+		exp = &CallExpression{}
+	default:
+		return nil, fmt.Errorf("unknow node kind(%s)", n.Kind)
+	}
+	if err := json.Unmarshal(data, exp); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+func marshalValue(kind string, value interface{}) ([]byte, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonNode{Kind: kind, Value: v})
+}
+
+func unmarshalValue(data []byte, kind string, value interface{}) error {
+	n, err := decodeNode(data, kind)
+	if err != nil {
+		return err
+	}
+	if len(n.Value) == 0 {
+		return fmt.Errorf("%s node missing value", kind)
+	}
+	return json.Unmarshal(n.Value, value)
+}
+
+func decodeNode(data []byte, kind string) (*jsonNode, error) {
+	n := &jsonNode{}
+	if err := json.Unmarshal(data, n); err != nil {
+		return nil, err
+	}
+	if n.Kind != kind {
+		return nil, fmt.Errorf("expect node kind %s, got %s", kind, n.Kind)
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package conditions
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgramJSON(t *testing.T) {
+	input := `(len(abc) > 1 && X == "123") || !(Y in [1, 2, 3]) || Z in ["a"] || now() - T > 7d && T < @2024-01-01T08:00:00+08:00`
+	program := NewParser(NewLexer(input)).ParseProgram()
+
+	data, err := json.Marshal(program)
+	assert.Nil(t, err)
+
+	loaded, err := ParseJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, program, loaded)
+
+	// web UI构造的JSON
+	loaded, err = ParseJSON([]byte(`{"version":1,"expression":{"kind":"infix","operator":">=",
+		"left":{"kind":"identifier","value":"age"},"right":{"kind":"integer","value":18}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "(age >= 18)", loaded.String())
+
+	_, err = ParseJSON([]byte(`{"version":3,"expression":{"kind":"boolean","value":true}}`))
+	assert.NotNil(t, err)
+	_, err = ParseJSON([]byte(`{"version":1,"expression":{"kind":"infix","operator":"*",
+		"left":{"kind":"integer","value":1},"right":{"kind":"integer","value":2}}}`))
+	assert.NotNil(t, err)
+}
+
+func TestDurationJSON(t *testing.T) {
+	for _, input := range []string{"1500us", "500us", "1ns", "1d2h3m4s5ms6us7ns", "0s"} {
+		program := NewParser(NewLexer("T > " + input)).ParseProgram()
+		data, err := json.Marshal(program)
+		assert.Nil(t, err, input)
+		loaded, err := ParseJSON(data)
+		assert.Nil(t, err, input)
+		assert.Equal(t, program, loaded, input)
+	}
+
+	for _, d := range []time.Duration{1500 * time.Microsecond, -7 * 24 * time.Hour, -time.Nanosecond, math.MaxInt64, math.MinInt64} {
+		data, err := json.Marshal(&Duration{Value: d})
+		assert.Nil(t, err)
+		var loaded Duration
+		assert.Nil(t, json.Unmarshal(data, &loaded), string(data))
+		assert.Equal(t, d, loaded.Value, string(data))
+	}
+}
+
+func TestEmptyProgramJSON(t *testing.T) {
+	data, err := json.Marshal(&Program{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"version":2}`, string(data))
+
+	loaded, err := ParseJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, &Program{}, loaded)
+
+	loaded, err = ParseJSON([]byte(`{"version":2,"expression":null}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &Program{}, loaded)
+}
@@ -1,5 +1,10 @@
 package conditions
 
+import (
+	"errors"
+	"strings"
+)
+
 // Lexer 代表一个词法解析器
 type Lexer struct {
 	input        string
@@ -21,6 +26,7 @@ func NewLexer(input string) *Lexer {
 func (l *Lexer) NextToken() Token {
 	var tok Token
 	l.skipWhitespace()
+	pos := l.position
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -30,6 +36,15 @@ func (l *Lexer) NextToken() Token {
 				Literal: "==",
 			}
 		}
+	case '+':
+		tok = newToken(PLUS, l.ch)
+	case '-':
+		tok = newToken(MINUS, l.ch)
+	case '@':
+		tok.Type = TIME
+		tok.Literal = l.readTime()
+		tok.Pos = pos
+		return tok
 	case '(':
 		tok = newToken(LPAREN, l.ch)
 	case ')':
@@ -66,14 +81,6 @@ func (l *Lexer) NextToken() Token {
 				Literal: "~=",
 			}
 		}
-	case 'i':
-		if l.peekChar() == 'n' {
-			l.readChar()
-			tok = Token{
-				Type:    IN,
-				Literal: "in",
-			}
-		}
 	case '!':
 		if l.peekChar() == '=' {
 			l.readChar()
@@ -114,15 +121,30 @@ func (l *Lexer) NextToken() Token {
 		if isLetter(l.ch) { // 数字
 			tok.Literal = l.readIdentifier()
 			tok.Type = LookupIdent(tok.Literal) // 关键字和用户定义的标识符区分开
+			tok.Pos = pos
 			return tok
 		}
 		if isDigit(l.ch) { // 标识符
 			tok.Type = INT
 			tok.Literal = l.readNumber()
+			if l.ch == '.' && isDigit(l.peekChar()) { // 浮点数, 1.5
+				l.readChar()
+				tok.Literal += "." + l.readNumber()
+				tok.Type = FLOAT
+			} else if isLetter(l.ch) { // 带有单位的时间间隔, 7d 1h30m
+				tok.Literal += l.readDurationUnits()
+				tok.Type = DURATION
+				// 超出范围的字面量由解析器报错，负号在解析器中处理，-106751d23h47m16s854ms775us808ns 是合法的
+				if _, err := parseDuration(tok.Literal); err != nil && !errors.Is(err, errDurationRange) {
+					tok.Type = ILLEGAL
+				}
+			}
+			tok.Pos = pos
 			return tok
 		}
 		tok = newToken(ILLEGAL, l.ch)
 	}
+	tok.Pos = pos
 	l.readChar()
 	return tok
 }
@@ -147,20 +169,43 @@ func (l *Lexer) readChar() {
 }
 
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	var out strings.Builder
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '\\' && (l.peekChar() == '"' || l.peekChar() == '\\') {
+			l.readChar()
+		} else if l.ch == '"' || l.ch == 0 {
 			break
 		}
+		out.WriteByte(l.ch)
 	}
-	return l.input[position:l.position]
+	return out.String()
 }
 
-// 读取一个标识符
+// 读取一个标识符，第一个字符之后可以是数字, ipv4
+// 标识符可以包含以.分隔的多段，例如 Order.Address.City
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) || (l.ch == '.' && isLetter(l.peekChar())) {
+		l.readChar()
+	}
+	return l.input[position:l.position]
+}
+
+// 读取@之后的时间字面量
+func (l *Lexer) readTime() string {
+	l.readChar()
+	position := l.position
+	for isDigit(l.ch) || isLetter(l.ch) || l.ch == '-' || l.ch == ':' || l.ch == '+' || l.ch == '.' {
+		l.readChar()
+	}
+	return l.input[position:l.position]
+}
+
+// 读取数字之后的时间单位，包括复合的时间间隔中后续的数字和单位
+func (l *Lexer) readDurationUnits() string {
+	position := l.position
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -0,0 +1,251 @@
+package conditions
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 检查结果的严重程度
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// DefaultMaxInItems in数组元素个数的默认上限
+const DefaultMaxInItems = 1000
+
+// RuleSource 规则文件中的一条规则
+type RuleSource struct {
+	ID     string
+	Expr   string
+	File   string
+	Line   int // 规则所在的行号
+	Column int // 表达式在第一行中的起始列号
+}
+
+// Diagnostic 规则检查发现的问题
+type Diagnostic struct {
+	File     string `json:"file"`
+	RuleID   string `json:"rule_id,omitempty"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s [%s]", d.File, d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+// LintOptions 规则检查的选项
+type LintOptions struct {
+	Schema     Schema // 为nil时不检查标识符是否声明，也不检查标识符的类型
+	MaxInItems int    // in数组元素个数的上限，<=0时使用DefaultMaxInItems
+}
+
+// ParseRules 解析规则文件，每条规则的格式为 `<id>: <expression>`
+// 以#开头的行是注释，以空白开头的行是上一条规则的续行
+func ParseRules(file string, data []byte) ([]RuleSource, []Diagnostic) {
+	var rules []RuleSource
+	var diags []Diagnostic
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case text[0] == ' ' || text[0] == '\t':
+			if len(rules) == 0 {
+				diags = append(diags, Diagnostic{File: file, Line: line, Column: 1, Severity: SeverityError,
+					Code: "syntax", Message: "continuation line without a rule"})
+				continue
+			}
+			rules[len(rules)-1].Expr += "\n" + text
+			continue
+		}
+		colon := strings.Index(text, ":")
+		if colon <= 0 || strings.TrimSpace(text[:colon]) == "" {
+			diags = append(diags, Diagnostic{File: file, Line: line, Column: 1, Severity: SeverityError,
+				Code: "syntax", Message: "expected `<id>: <expression>`"})
+			continue
+		}
+		rules = append(rules, RuleSource{
+			ID:     strings.TrimSpace(text[:colon]),
+			Expr:   text[colon+1:],
+			File:   file,
+			Line:   line,
+			Column: colon + 2,
+		})
+	}
+	return rules, diags
+}
+
+// Lint 对规则进行解析、类型检测和语义检查
+//   - 语法错误、类型错误(例如字符串和整数比较)、未知的内置函数
+//   - 未在schema中声明的标识符
+//   - 重复的规则ID
+//   - 恒为真或恒为假的规则，空的或元素过多的in数组
+//   - 字符串的大小比较，按照字典序比较，通常是误用(例如版本号)
+func Lint(rules []RuleSource, opts LintOptions) []Diagnostic {
+	if opts.MaxInItems <= 0 {
+		opts.MaxInItems = DefaultMaxInItems
+	}
+	var diags []Diagnostic
+	seen := map[string]RuleSource{}
+	for _, rule := range rules {
+		if prev, ok := seen[rule.ID]; ok {
+			diags = append(diags, rule.diagnostic(-1, SeverityError, "duplicate-id",
+				fmt.Sprintf("duplicate rule id %q, first defined at %s:%d", rule.ID, prev.File, prev.Line)))
+		} else {
+			seen[rule.ID] = rule
+		}
+		diags = append(diags, lintRule(rule, opts)...)
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].File != diags[j].File {
+			return diags[i].File < diags[j].File
+		}
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+	return diags
+}
+
+// 根据错误信息的前缀对解析和类型检测的错误分类
+var parseErrorCodes = []struct {
+	prefix string
+	code   string
+}{
+	{"CallExpression unknow function", "unknown-builtin"},
+	{"InfixExpression", "type-mismatch"},
+	{"PrefixExpresion", "type-mismatch"},
+	{"CallExpression", "type-mismatch"},
+}
+
+func lintRule(rule RuleSource, opts LintOptions) []Diagnostic {
+	var diags []Diagnostic
+	p := NewParser(NewLexer(rule.Expr))
+	p.SetSchema(opts.Schema)
+	program := p.ParseProgram()
+	for _, e := range p.ErrorDetails() {
+		code := "syntax"
+		for _, c := range parseErrorCodes {
+			if strings.HasPrefix(e.Msg, c.prefix) {
+				code = c.code
+				break
+			}
+		}
+		diags = append(diags, rule.diagnostic(e.Pos, SeverityError, code, e.Msg))
+	}
+	if program.Expression == nil {
+		return diags
+	}
+	for _, d := range diags {
+		if d.Code == "syntax" {
+			return diags
+		}
+	}
+
+	if opts.Schema != nil {
+		for _, name := range Variables(program) {
+			if _, ok := opts.Schema[name]; !ok {
+				diags = append(diags, rule.diagnostic(identPos(p, program, name), SeverityError,
+					"unknown-identifier", fmt.Sprintf("identifier %s is not declared in schema", name)))
+			}
+		}
+	}
+
+	if len(diags) != 0 {
+		return diags
+	}
+
+	Inspect(program, func(node Node) bool {
+		ie, ok := node.(*InfixExpression)
+		if !ok {
+			return true
+		}
+		switch ie.Operator {
+		case LT, LT_EQUAL, GT, GT_EQUAL:
+			if p.CheckType(ie.Left) == STRING_OBJ || p.CheckType(ie.Right) == STRING_OBJ {
+				diags = append(diags, rule.diagnostic(p.nodePos(ie), SeverityWarning, "string-order",
+					fmt.Sprintf("string comparison %s is lexicographic, \"1.10\" < \"1.9\", "+
+						"use semver_compare() or semver_satisfies() for versions", ie.String())))
+			}
+		case IN:
+			size := -1
+			if arr, ok := ie.Right.(*Array); ok {
+				size = len(arr.Elements)
+			}
+			if size == 0 {
+				diags = append(diags, rule.diagnostic(p.nodePos(ie.Right), SeverityWarning, "empty-in",
+					"in with an empty array is always false"))
+			} else if size > opts.MaxInItems {
+				diags = append(diags, rule.diagnostic(p.nodePos(ie.Right), SeverityWarning, "oversized-in",
+					fmt.Sprintf("in array has %d items, more than %d", size, opts.MaxInItems)))
+			}
+		}
+		return true
+	})
+
+	optimized, warnings := Optimize(program)
+	for _, w := range warnings {
+		code := "redundant"
+		if strings.HasSuffix(w, "is always true") {
+			code = "always-true"
+		} else if strings.HasSuffix(w, "is always false") {
+			code = "always-false"
+		}
+		diags = append(diags, rule.diagnostic(0, SeverityWarning, code, w))
+	}
+	if _, ok := optimized.Expression.(*Boolean); ok || len(diags) != 0 || opts.Schema == nil {
+		return diags
+	}
+	// 结合schema判断是否恒为真或恒为假，不支持的写法忽略
+	if sat, _, err := Satisfiable(program, opts.Schema); err == nil && !sat {
+		diags = append(diags, rule.diagnostic(0, SeverityWarning, "always-false",
+			fmt.Sprintf("rule %s can never be true", Format(program))))
+	}
+	negated := &Program{Expression: &PrefixExpresion{Operator: BANG, Right: program.Expression}}
+	if sat, _, err := Satisfiable(negated, opts.Schema); err == nil && !sat {
+		diags = append(diags, rule.diagnostic(0, SeverityWarning, "always-true",
+			fmt.Sprintf("rule %s is always true", Format(program))))
+	}
+	return diags
+}
+
+// identPos 返回标识符第一次出现的位置
+func identPos(p *Parser, program *Program, name string) int {
+	pos := -1
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok && ident.Value == name && pos < 0 {
+			pos = p.nodePos(ident)
+		}
+		return pos < 0
+	})
+	return pos
+}
+
+// diagnostic 将表达式中的位置转换为文件中的行号和列号，pos<0时指向规则的开头
+func (r RuleSource) diagnostic(pos int, severity, code, msg string) Diagnostic {
+	d := Diagnostic{File: r.File, RuleID: r.ID, Severity: severity, Code: code, Message: msg, Line: r.Line, Column: 1}
+	if pos < 0 {
+		return d
+	}
+	if pos > len(r.Expr) {
+		pos = len(r.Expr)
+	}
+	line, column := lineColumn(r.Expr, pos)
+	d.Line = r.Line + line - 1
+	if line == 1 {
+		column += r.Column - 1
+	}
+	d.Column = column
+	return d
+}
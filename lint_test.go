@@ -0,0 +1,38 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	src := `# rules
+adult: age >= 18 &&
+    country in ["CN", "SG"]
+dup: age > 1
+dup: name == 1
+never: age > 18 && age < 10
+unknown: len(nickname) > 0
+long: len(name) > 70000
+version: name >= "1.10.0"
+`
+	rules, diags := ParseRules("a.rules", []byte(src))
+	assert.Empty(t, diags)
+	assert.Len(t, rules, 7)
+	assert.Equal(t, "age >= 18 &&\n    country in [\"CN\", \"SG\"]", rules[0].Expr[1:])
+
+	diags = Lint(rules, LintOptions{Schema: testSchema})
+	var codes []string
+	for _, d := range diags {
+		codes = append(codes, d.String())
+	}
+	assert.Equal(t, []string{
+		`a.rules:5:1: error: duplicate rule id "dup", first defined at a.rules:4 [duplicate-id]`,
+		`a.rules:5:14: error: InfixExpression <exp>==<exp> right expect STRING, got INTEGER [type-mismatch]`,
+		`a.rules:6:7: warning: rule age > 18 && age < 10 can never be true [always-false]`,
+		`a.rules:7:14: error: identifier nickname is not declared in schema [unknown-identifier]`,
+		`a.rules:9:10: warning: string comparison (name >= "1.10.0") is lexicographic, "1.10" < "1.9", ` +
+			`use semver_compare() or semver_satisfies() for versions [string-order]`,
+	}, codes)
+}
@@ -0,0 +1,303 @@
+package conditions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToMongo 将Program翻译为MongoDB的filter文档，结构与bson.M相同
+//
+// 求值时未绑定的标识符会导致不匹配，所以否定形式的条件会额外要求字段存在($exists)
+func ToMongo(program *Program) (map[string]interface{}, error) {
+	t := &mongoTranslator{}
+	filter := t.translate(program.Expression)
+	if len(t.errors) != 0 {
+		return nil, fmt.Errorf("cannot translate to mongo: %s", strings.Join(t.errors, "; "))
+	}
+	return filter, nil
+}
+
+// 比较运算符到mongo运算符的映射
+var mongoOperators = map[TokenType]string{
+	NOT_EQ:   "$ne",
+	LT:       "$lt",
+	LT_EQUAL: "$lte",
+	GT:       "$gt",
+	GT_EQUAL: "$gte",
+}
+
+// 字面量在左侧时，交换两侧需要翻转的运算符
+var flippedOperators = map[TokenType]TokenType{
+	EQ:       EQ,
+	NOT_EQ:   NOT_EQ,
+	LT:       GT,
+	LT_EQUAL: GT_EQUAL,
+	GT:       LT,
+	GT_EQUAL: LT_EQUAL,
+}
+
+type mongoTranslator struct {
+	errors []string
+}
+
+func (t *mongoTranslator) errorf(format string, args ...interface{}) map[string]interface{} {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (t *mongoTranslator) translate(node Expression) map[string]interface{} {
+	switch n := node.(type) {
+	case *Identifier:
+		return map[string]interface{}{n.Value: true}
+	case *Boolean:
+		if n.Value {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{"$expr": false}
+	case *PrefixExpresion:
+		if n.Operator != BANG {
+			return t.errorf("unsupported prefix operator %s", n.Operator)
+		}
+		return t.negate(n.Right)
+	case *InfixExpression:
+		switch n.Operator {
+		case AND:
+			return map[string]interface{}{"$and": t.translateChain(n, AND)}
+		case OR:
+			return map[string]interface{}{"$or": t.translateChain(n, OR)}
+		case IN:
+			field, values, ok := t.in(n)
+			if !ok {
+				return nil
+			}
+			return map[string]interface{}{field: map[string]interface{}{"$in": values}}
+		case REG:
+			return t.regexp(n.Left, n.Right)
+		}
+		return t.comparison(n)
+	case *CallExpression:
+		return t.translateCall(n)
+	}
+	return t.errorf("unsupported expression %s", node.String())
+}
+
+// translateChain 将同一运算符的链展开为数组，(a && b) && c => [a, b, c]
+func (t *mongoTranslator) translateChain(n *InfixExpression, operator TokenType) []interface{} {
+	var ret []interface{}
+	for _, operand := range flattenChain(n, operator) {
+		ret = append(ret, t.translate(operand))
+	}
+	return ret
+}
+
+// negate 翻译 !<expression>
+func (t *mongoTranslator) negate(node Expression) map[string]interface{} {
+	switch n := node.(type) {
+	case *Identifier:
+		return map[string]interface{}{n.Value: map[string]interface{}{"$exists": true, "$ne": true}}
+	case *PrefixExpresion:
+		if n.Operator == BANG {
+			return t.translate(n.Right)
+		}
+	case *InfixExpression:
+		switch n.Operator {
+		case IN:
+			field, values, ok := t.in(n)
+			if !ok {
+				return nil
+			}
+			return map[string]interface{}{field: map[string]interface{}{"$exists": true, "$nin": values}}
+		case EQ, NOT_EQ, LT, LT_EQUAL, GT, GT_EQUAL:
+			if isLenCall(n.Left) || isLenCall(n.Right) {
+				return t.length(n, true)
+			}
+			field, operator, value, ok := t.operands(n)
+			if !ok {
+				return nil
+			}
+			cond := map[string]interface{}{"$exists": true}
+			if operator == EQ {
+				cond["$ne"] = value
+			} else if operator == NOT_EQ {
+				cond["$eq"] = value
+			} else {
+				cond["$not"] = map[string]interface{}{mongoOperators[operator]: value}
+			}
+			return map[string]interface{}{field: cond}
+		}
+	}
+	return map[string]interface{}{"$nor": []interface{}{t.translate(node)}}
+}
+
+func (t *mongoTranslator) comparison(n *InfixExpression) map[string]interface{} {
+	if isLenCall(n.Left) || isLenCall(n.Right) {
+		return t.length(n, false)
+	}
+	field, operator, value, ok := t.operands(n)
+	if !ok {
+		return nil
+	}
+	if operator == EQ {
+		return map[string]interface{}{field: value}
+	}
+	cond := map[string]interface{}{mongoOperators[operator]: value}
+	if operator == NOT_EQ {
+		cond["$exists"] = true
+	}
+	return map[string]interface{}{field: cond}
+}
+
+// operands 返回比较表达式中的字段、运算符和字面量，字面量在左侧时翻转运算符
+func (t *mongoTranslator) operands(n *InfixExpression) (string, TokenType, interface{}, bool) {
+	operator, ok := flippedOperators[n.Operator]
+	if !ok {
+		t.errorf("unsupported operator %s", n.Operator)
+		return "", "", nil, false
+	}
+	left, right := n.Left, n.Right
+	if _, ok := right.(*Identifier); ok {
+		left, right = right, left
+	} else {
+		operator = n.Operator
+	}
+	ident, ok := left.(*Identifier)
+	if !ok {
+		t.errorf("comparison %s must be between a field and a literal", n.String())
+		return "", "", nil, false
+	}
+	value, ok := literalValue(right)
+	if !ok {
+		t.errorf("comparison %s must be between a field and a literal", n.String())
+		return "", "", nil, false
+	}
+	return ident.Value, operator, value, true
+}
+
+func (t *mongoTranslator) in(n *InfixExpression) (string, []interface{}, bool) {
+	ident, ok := n.Left.(*Identifier)
+	if !ok {
+		t.errorf("left side of in must be a field, got %s", n.Left.String())
+		return "", nil, false
+	}
+	if arr, ok := n.Right.(*Array); ok {
+		if _, ok := cidrList(arr); ok {
+			t.errorf("%s matches CIDRs, which mongo does not support", n.String())
+			return "", nil, false
+		}
+	}
+	value, ok := literalValue(n.Right)
+	values, isArray := value.([]interface{})
+	if !ok || !isArray {
+		t.errorf("right side of in must be an array literal, got %s", n.Right.String())
+		return "", nil, false
+	}
+	return ident.Value, values, true
+}
+
+// length 翻译 len(field) <op> n 和 n <op> len(field)，同时支持数组和字符串字段
+// 字段不存在或者不是数组和字符串时和求值出错一样不匹配，negate为true时只对比较取反
+func (t *mongoTranslator) length(n *InfixExpression, negate bool) map[string]interface{} {
+	if !isLenCall(n.Left) {
+		operator, ok := flippedOperators[n.Operator]
+		if !ok {
+			return t.errorf("unsupported operator %s", n.Operator)
+		}
+		n = &InfixExpression{Left: n.Right, Operator: operator, Right: n.Left}
+	}
+	call := n.Left.(*CallExpression)
+	if len(call.Arguments) != 1 {
+		return t.errorf("wrong number of argument to len. got=%d, want=1", len(call.Arguments))
+	}
+	ident, ok := call.Arguments[0].(*Identifier)
+	if !ok {
+		return t.errorf("argument to len must be a field, got %s", call.Arguments[0].String())
+	}
+	size, ok := n.Right.(*Integer)
+	if !ok {
+		return t.errorf("len() must be compared with an integer, got %s", n.Right.String())
+	}
+	operator := "$eq"
+	if n.Operator != EQ {
+		operator = mongoOperators[n.Operator]
+	}
+	if operator == "" {
+		return t.errorf("unsupported operator %s", n.Operator)
+	}
+	field := "$" + ident.Value
+	compare := func(length interface{}) interface{} {
+		cmp := map[string]interface{}{operator: []interface{}{length, size.Value}}
+		if negate {
+			return map[string]interface{}{"$not": []interface{}{cmp}}
+		}
+		return cmp
+	}
+	// $cond只对选中的分支求值，$strLenCP不会作用于缺失的字段和其它类型的值
+	return map[string]interface{}{
+		"$expr": map[string]interface{}{"$cond": []interface{}{
+			map[string]interface{}{"$isArray": field},
+			compare(map[string]interface{}{"$size": field}),
+			map[string]interface{}{"$cond": []interface{}{
+				map[string]interface{}{"$eq": []interface{}{map[string]interface{}{"$type": field}, "string"}},
+				compare(map[string]interface{}{"$strLenCP": field}),
+				false,
+			}},
+		}},
+	}
+}
+
+func isLenCall(exp Expression) bool {
+	call, ok := exp.(*CallExpression)
+	return ok && call.Function.String() == "len"
+}
+
+func (t *mongoTranslator) translateCall(n *CallExpression) map[string]interface{} {
+	switch name := n.Function.String(); name {
+	case "regexp":
+		if len(n.Arguments) != 2 {
+			return t.errorf("wrong number of argument to regexp. got=%d, want=2", len(n.Arguments))
+		}
+		return t.regexp(n.Arguments[0], n.Arguments[1])
+	default:
+		return t.errorf("unsupported builtin %s", name)
+	}
+}
+
+// regexp 翻译 regexp(field, pattern) 和 field ~= pattern
+func (t *mongoTranslator) regexp(field, pattern Expression) map[string]interface{} {
+	ident, ok := field.(*Identifier)
+	if !ok {
+		return t.errorf("first argument to regexp must be a field, got %s", field.String())
+	}
+	p, ok := pattern.(*String)
+	if !ok {
+		return t.errorf("second argument to regexp must be a string, got %s", pattern.String())
+	}
+	return map[string]interface{}{ident.Value: map[string]interface{}{"$regex": p.Value}}
+}
+
+// literalValue 将字面量转换为go的原生类型
+func literalValue(exp Expression) (interface{}, bool) {
+	switch n := exp.(type) {
+	case *Integer:
+		return n.Value, true
+	case *String:
+		return n.Value, true
+	case *Boolean:
+		return n.Value, true
+	case *Time:
+		return n.Value, true
+	case *Float:
+		return n.Value, true
+	case *Array:
+		ret := make([]interface{}, 0, len(n.Elements))
+		for _, elem := range n.Elements {
+			v, ok := literalValue(elem)
+			if !ok {
+				return nil, false
+			}
+			ret = append(ret, v)
+		}
+		return ret, true
+	}
+	return nil, false
+}
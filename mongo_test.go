@@ -0,0 +1,55 @@
+package conditions
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testGolden 翻译testdata/<dir>下的每个.cond文件，与同名的.golden文件比较
+func testGolden(t *testing.T, dir string, translate func(*Program) (interface{}, error)) {
+	files, err := filepath.Glob(filepath.Join("testdata", dir, "*.cond"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		p := NewParser(NewLexer(string(src)))
+		program := p.ParseProgram()
+		assert.Empty(t, p.Errors(), file)
+
+		ret, err := translate(program)
+		assert.Nil(t, err, file)
+		got, err := json.MarshalIndent(ret, "", "  ")
+		assert.Nil(t, err)
+		got = append(got, '\n')
+
+		golden := strings.TrimSuffix(file, ".cond") + ".golden"
+		if *update {
+			assert.Nil(t, ioutil.WriteFile(golden, got, 0644))
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.Equal(t, string(want), string(got), file)
+	}
+}
+
+func TestToMongoGolden(t *testing.T) {
+	testGolden(t, "mongo", func(program *Program) (interface{}, error) {
+		return ToMongo(program)
+	})
+}
+
+func TestToMongoUnsupported(t *testing.T) {
+	program := NewParser(NewLexer(`zero(name) || len(name) > "a"`)).ParseProgram()
+	_, err := ToMongo(program)
+	assert.EqualError(t, err, `cannot translate to mongo: unsupported builtin zero; len() must be compared with an integer, got "a"`)
+}
@@ -0,0 +1,252 @@
+package conditions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 取反后对应的比较运算符
+var negatedOperators = map[TokenType]TokenType{
+	EQ:       NOT_EQ,
+	NOT_EQ:   EQ,
+	LT:       GT_EQUAL,
+	LT_EQUAL: GT,
+	GT:       LT_EQUAL,
+	GT_EQUAL: LT,
+}
+
+// negationNormalForm 将 ! 下推到原子条件上，negate表示当前表达式需要取反
+// !(a && b) => !a || !b, !(a > 1) => a <= 1, !!(a > 1) => a > 1
+//
+// 非bool的值取反总是false，!!a 和 a 不等价，!(a && b) 和 !a || !b 也不等价，
+// 因此只有操作数一定是bool时才下推，否则整体作为原子条件
+func negationNormalForm(exp Expression, negate bool) Expression {
+	switch n := exp.(type) {
+	case *Boolean:
+		return nativeBoolToBooleanObject(n.Value != negate)
+	case *PrefixExpresion:
+		if n.Operator == BANG && isBooleanExpression(n.Right) {
+			return negationNormalForm(n.Right, !negate)
+		}
+	case *InfixExpression:
+		switch n.Operator {
+		case AND, OR:
+			if negate && (!isBooleanExpression(n.Left) || !isBooleanExpression(n.Right)) {
+				break
+			}
+			operator := n.Operator
+			if negate {
+				operator = map[TokenType]TokenType{AND: OR, OR: AND}[operator]
+			}
+			return &InfixExpression{
+				Left:     negationNormalForm(n.Left, negate),
+				Operator: operator,
+				Right:    negationNormalForm(n.Right, negate),
+			}
+		}
+		if operator, ok := negatedOperators[n.Operator]; ok && negate {
+			return &InfixExpression{Left: n.Left, Operator: operator, Right: n.Right}
+		}
+	}
+	if negate {
+		return &PrefixExpresion{Operator: BANG, Right: exp}
+	}
+	return exp
+}
+
+// disjunctiveClauses 将否定范式的表达式展开为析取范式，每个子切片是一个合取子句
+// 子句数量超过limit时返回error
+func disjunctiveClauses(exp Expression, limit int) ([][]Expression, error) {
+	return normalClauses(exp, OR, limit)
+}
+
+// normalClauses 将否定范式的表达式展开为以outer连接的子句，子句内部以另一个运算符连接
+// outer为OR时得到析取范式，为AND时得到合取范式
+func normalClauses(exp Expression, outer TokenType, limit int) ([][]Expression, error) {
+	ie, ok := exp.(*InfixExpression)
+	if !ok || (ie.Operator != AND && ie.Operator != OR) {
+		return [][]Expression{{exp}}, nil
+	}
+	left, err := normalClauses(ie.Left, outer, limit)
+	if err != nil {
+		return nil, err
+	}
+	right, err := normalClauses(ie.Right, outer, limit)
+	if err != nil {
+		return nil, err
+	}
+	if ie.Operator == outer {
+		if len(left)+len(right) > limit {
+			return nil, fmt.Errorf("normal form exceeds %d clauses", limit)
+		}
+		return append(left, right...), nil
+	}
+	if len(left)*len(right) > limit {
+		return nil, fmt.Errorf("normal form exceeds %d clauses", limit)
+	}
+	clauses := make([][]Expression, 0, len(left)*len(right))
+	for _, l := range left {
+		for _, r := range right {
+			clause := make([]Expression, 0, len(l)+len(r))
+			clause = append(append(clause, l...), r...)
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses, nil
+}
+
+// DNF 将Program转换为析取范式, (a && b) || (c && d)
+// ! 会被下推到原子条件上，子句数量超过limit时返回error，limit<=0时使用DefaultClauseLimit
+func DNF(program *Program, limit int) (*Program, error) {
+	return normalForm(program, OR, limit)
+}
+
+// CNF 将Program转换为合取范式, (a || b) && (c || d)
+func CNF(program *Program, limit int) (*Program, error) {
+	return normalForm(program, AND, limit)
+}
+
+func normalForm(program *Program, outer TokenType, limit int) (*Program, error) {
+	if limit <= 0 {
+		limit = DefaultClauseLimit
+	}
+	clauses, err := normalClauses(negationNormalForm(program.Expression, false), outer, limit)
+	if err != nil {
+		return nil, err
+	}
+	inner := map[TokenType]TokenType{AND: OR, OR: AND}[outer]
+	clauses = minimizeClauses(clauses, inner)
+
+	var exp Expression
+	for _, clause := range clauses {
+		var c Expression
+		for _, atom := range clause {
+			c = joinExpression(c, inner, atom)
+		}
+		exp = joinExpression(exp, outer, c)
+	}
+	if exp == nil {
+		// 析取范式没有子句时为false，合取范式没有子句时为true
+		exp = nativeBoolToBooleanObject(outer == AND)
+	}
+	return &Program{Expression: exp}, nil
+}
+
+func joinExpression(left Expression, operator TokenType, right Expression) Expression {
+	if left == nil {
+		return right
+	}
+	return &InfixExpression{Left: left, Operator: operator, Right: right}
+}
+
+// minimizeClauses 化简范式的子句，inner为子句内部的连接运算符
+//   - 去除子句中重复的原子条件
+//   - 子句中同时出现x和!x时，合取子句恒为假，析取子句恒为真，去除该子句
+//   - 子句中的true/false按照单位元和零元处理
+//   - 一个子句包含另一个子句的全部原子条件时，去除较大的子句
+//   - 两个单原子子句互补时，整个范式恒为真或恒为假
+//
+// 原子条件和子句都会按照格式化后的源码排序，得到规范的形式
+func minimizeClauses(clauses [][]Expression, inner TokenType) [][]Expression {
+	// 合取子句中的true，析取子句中的false可以去除
+	identity := inner == AND
+	type clause struct {
+		atoms map[string]Expression
+		keys  []string
+	}
+	var reduced []clause
+	var empty bool
+next:
+	for _, atoms := range clauses {
+		c := clause{atoms: map[string]Expression{}}
+		for _, atom := range atoms {
+			if b, ok := atom.(*Boolean); ok {
+				if b.Value == identity {
+					continue
+				}
+				// 零元使整个子句被吸收
+				continue next
+			}
+			key := Format(atom)
+			if complementIn(atom, c.atoms) {
+				continue next
+			}
+			if _, ok := c.atoms[key]; !ok {
+				c.atoms[key] = atom
+				c.keys = append(c.keys, key)
+			}
+		}
+		if len(c.keys) == 0 {
+			// 空子句：合取为真，析取为假，吸收其它所有子句
+			empty = true
+			break
+		}
+		sort.Strings(c.keys)
+		reduced = append(reduced, c)
+	}
+	if empty {
+		return [][]Expression{{nativeBoolToBooleanObject(identity)}}
+	}
+
+	// 先处理较小的子句，去除被包含的子句
+	sort.SliceStable(reduced, func(i, j int) bool {
+		if len(reduced[i].keys) != len(reduced[j].keys) {
+			return len(reduced[i].keys) < len(reduced[j].keys)
+		}
+		return strings.Join(reduced[i].keys, "\x00") < strings.Join(reduced[j].keys, "\x00")
+	})
+	var kept []clause
+	for _, c := range reduced {
+		subsumed := false
+		for _, k := range kept {
+			if subset(k.keys, c.atoms) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			kept = append(kept, c)
+		}
+	}
+
+	// 两个只有一个原子条件的子句互补时, x || !x 恒为真, x && !x 恒为假
+	units := map[string]Expression{}
+	for _, c := range kept {
+		if len(c.keys) != 1 {
+			continue
+		}
+		if complementIn(c.atoms[c.keys[0]], units) {
+			return [][]Expression{{nativeBoolToBooleanObject(identity)}}
+		}
+		units[c.keys[0]] = c.atoms[c.keys[0]]
+	}
+
+	ret := make([][]Expression, 0, len(kept))
+	for _, c := range kept {
+		atoms := make([]Expression, 0, len(c.keys))
+		for _, key := range c.keys {
+			atoms = append(atoms, c.atoms[key])
+		}
+		ret = append(ret, atoms)
+	}
+	return ret
+}
+
+// complementIn 判断atoms中是否有atom的否定，非bool的值和它的否定可以同时为假，不是互补的
+func complementIn(atom Expression, atoms map[string]Expression) bool {
+	if !isBooleanExpression(atom) {
+		return false
+	}
+	_, ok := atoms[Format(negationNormalForm(atom, true))]
+	return ok
+}
+
+func subset(keys []string, set map[string]Expression) bool {
+	for _, key := range keys {
+		if _, ok := set[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalForms(t *testing.T) {
+	tests := []struct {
+		input string
+		dnf   string
+		cnf   string
+	}{
+		{
+			`!(a > 1) && (b || c)`,
+			`a <= 1 && b || (a <= 1 && c)`,
+			`a <= 1 && (b || c)`,
+		},
+		{
+			`(a == 1 || b) && (a == 1 || c)`,
+			`a == 1 || (b && c)`,
+			`a == 1 || b && (a == 1 || c)`,
+		},
+		{
+			`!(x > 1 && !(y > 1)) || x > 1`,
+			`true`,
+			`true`,
+		},
+		// 非bool的值取反总是false，不能使用德摩根定律和双重否定，也不能和自己的否定互补
+		{
+			`!(x && !y) || x`,
+			`!(x && !y) || x`,
+			`!(x && !y) || x`,
+		},
+		{
+			`!!x && (y || !y)`,
+			`!!x && !y || (!!x && y)`,
+			`!!x && (!y || y)`,
+		},
+		{
+			`a > 1 && (b || !b) && !(a > 1 || c in [1])`,
+			`false`,
+			`false`,
+		},
+	}
+	// && 和 || 的优先级相同且左结合，格式化时第一个子句不需要括号
+	for _, tt := range tests {
+		program := mustCompile(t, tt.input)
+		dnf, err := DNF(program, 0)
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.dnf, Format(dnf), tt.input)
+		cnf, err := CNF(program, 0)
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.cnf, Format(cnf), tt.input)
+	}
+
+	// 变量是整数时范式和原表达式的值相同
+	for _, input := range []string{`!(x && !y) || x`, `!!x && (y || !y)`, `!(x || y) || !(x > 0)`} {
+		program := mustCompile(t, input)
+		dnf, err := DNF(program, 0)
+		assert.Nil(t, err, input)
+		cnf, err := CNF(program, 0)
+		assert.Nil(t, err, input)
+		for _, x := range []int64{0, 1} {
+			for _, y := range []int64{0, 1} {
+				env := NewEnvironment()
+				env.Set("x", &Integer{Value: x})
+				env.Set("y", &Integer{Value: y})
+				expect := Eval(program, env)
+				assert.Equal(t, expect, Eval(dnf, env), "%s x=%d y=%d", Format(dnf), x, y)
+				assert.Equal(t, expect, Eval(cnf, env), "%s x=%d y=%d", Format(cnf), x, y)
+			}
+		}
+	}
+
+	_, err := DNF(mustCompile(t, `(a || b) && (c || d) && (e || f)`), 4)
+	assert.EqualError(t, err, "normal form exceeds 4 clauses")
+}
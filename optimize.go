@@ -0,0 +1,121 @@
+package conditions
+
+import "fmt"
+
+// Optimize 对Program进行常量折叠和布尔代数化简
+//
+// 返回化简后的Program，以及优化过程中发现的可疑写法，
+// 例如恒为真或恒为假的规则，这类规则通常是编写错误
+func Optimize(program *Program) (*Program, []string) {
+	o := &optimizer{env: NewEnvironment()}
+	exp := o.optimize(program.Expression)
+	if b, ok := exp.(*Boolean); ok && !isLiteral(program.Expression) {
+		o.warn("rule %s is always %v", program.String(), b.Value)
+	}
+	return &Program{Expression: exp}, o.warnings
+}
+
+type optimizer struct {
+	env      *Environment // 空环境，只用来对字面量求值
+	warnings []string     // 记录优化过程中的告警
+}
+
+func (o *optimizer) warn(format string, args ...interface{}) {
+	o.warnings = append(o.warnings, fmt.Sprintf(format, args...))
+}
+
+func (o *optimizer) optimize(node Expression) Expression {
+	switch node := node.(type) {
+	case *PrefixExpresion:
+		right := o.optimize(node.Right)
+		// !(!x) => x，只对结果为bool的表达式化简，!对其它值的结果总是bool
+		if node.Operator == BANG {
+			if inner, ok := right.(*PrefixExpresion); ok && inner.Operator == BANG && isBooleanExpression(inner.Right) {
+				return inner.Right
+			}
+		}
+		exp := &PrefixExpresion{Operator: node.Operator, Right: right}
+		if isLiteral(right) {
+			return o.fold(exp)
+		}
+		return exp
+	case *InfixExpression:
+		exp := &InfixExpression{
+			Left:     o.optimize(node.Left),
+			Operator: node.Operator,
+			Right:    o.optimize(node.Right),
+		}
+		if isLiteral(exp.Left) && isLiteral(exp.Right) {
+			return o.fold(exp)
+		}
+		switch exp.Operator {
+		case AND, OR:
+			// x && x => x, x || x => x
+			if exp.Left.String() == exp.Right.String() && isBooleanExpression(exp.Left) {
+				o.warn("duplicate operand %s in %s", exp.Left.String(), exp.String())
+				return exp.Left
+			}
+			if isLiteral(exp.Left) || isLiteral(exp.Right) {
+				o.warn("constant operand in %s", exp.String())
+			}
+			return partialLogical(exp, exp.Operator == OR)
+		case EQ, NOT_EQ:
+			// x == true => x, x != false => x，只对结果为bool的表达式化简
+			if b, ok := exp.Right.(*Boolean); ok && isBooleanExpression(exp.Left) {
+				if b.Value == (exp.Operator == EQ) {
+					return exp.Left
+				}
+				return &PrefixExpresion{Operator: BANG, Right: exp.Left}
+			}
+		}
+		return exp
+	case *Array:
+		elems := make([]Expression, 0, len(node.Elements))
+		for _, e := range node.Elements {
+			elems = append(elems, o.optimize(e))
+		}
+		return NewArray(elems...)
+	case *CallExpression:
+		exp := &CallExpression{Function: node.Function, Arguments: make([]Expression, 0, len(node.Arguments))}
+		known := true
+		for _, a := range node.Arguments {
+			arg := o.optimize(a)
+			known = known && isLiteral(arg)
+			exp.Arguments = append(exp.Arguments, arg)
+		}
+		if known {
+			return o.fold(exp)
+		}
+		return exp
+	}
+	return node
+}
+
+// fold 对只包含字面量的表达式进行常量折叠，求值出错时保留原表达式
+func (o *optimizer) fold(exp Expression) Expression {
+	lit, err := foldExpression(exp, o.env)
+	if err != nil {
+		o.warn("%s", err)
+		return exp
+	}
+	return lit
+}
+
+// isBooleanExpression 判断表达式的结果是否一定是bool
+func isBooleanExpression(exp Expression) bool {
+	switch exp := exp.(type) {
+	case *Boolean:
+		return true
+	case *PrefixExpresion:
+		return exp.Operator == BANG
+	case *InfixExpression:
+		switch exp.Operator {
+		case AND, OR, EQ, NOT_EQ, LT, LT_EQUAL, GT, GT_EQUAL, IN, REG:
+			return true
+		}
+	case *CallExpression:
+		q, ok := quantifiers[exp.Function.String()]
+		return ok && q.returnType == BOOLEAN_OBJ
+	}
+	return false
+}
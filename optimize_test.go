@@ -0,0 +1,64 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		warnings int
+	}{
+		{`true && x > 1`, `(x > 1)`, 1},
+		{`!(!(x > 1))`, `(x > 1)`, 0},
+		{`3 in [1, 2, 3]`, `true`, 1},
+		{`x > 1 || 3 in [1, 2, 3]`, `true`, 2},
+		{`len("abc") > 5 && x == 1`, `false`, 2},
+		{`(x > 1) == true`, `(x > 1)`, 0},
+		{`x > 1 && x > 1`, `(x > 1)`, 1},
+	}
+	for _, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		program := p.ParseProgram()
+		optimized, warnings := Optimize(program)
+		assert.Equal(t, tt.expected, optimized.String(), tt.input)
+		assert.Equal(t, tt.warnings, len(warnings), tt.input)
+	}
+}
+
+func TestOptimizeKeepsResult(t *testing.T) {
+	envs := []map[string]Object{
+		{"x": &Integer{Value: 5}, "y": &Integer{Value: 0}},
+		{"x": &Integer{Value: 0}, "y": &String{Value: "a"}},
+		{"x": &String{Value: ""}, "y": boolTrue},
+		{"x": boolFalse, "y": boolFalse},
+	}
+	for _, input := range []string{
+		`!!x`,
+		`!(!(x))`,
+		`true && x`,
+		`x && true`,
+		`false || x`,
+		`x && x`,
+		`x || x`,
+		`!!(x + y)`,
+		`true && x + 1`,
+		`x == true`,
+	} {
+		program, err := Compile(input)
+		if !assert.Nil(t, err, input) {
+			continue
+		}
+		optimized, _ := Optimize(program)
+		for _, vars := range envs {
+			env := NewEnvironment()
+			for name, obj := range vars {
+				env.Set(name, obj)
+			}
+			assert.Equal(t, Eval(program, env), Eval(optimized, env), "%s => %s with %v", input, optimized, vars)
+		}
+	}
+}
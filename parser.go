@@ -3,6 +3,8 @@ package conditions
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type (
@@ -31,10 +33,13 @@ var precedences = map[TokenType]int{
 	OR:       COND,        // ||
 	EQ:       EQUALS,      // ==
 	NOT_EQ:   EQUALS,      // !=
+	REG:      EQUALS,      // ~=
 	LT:       LESSGREATER, // <
 	LT_EQUAL: LESSGREATER, // <=
 	GT:       LESSGREATER, // >
 	GT_EQUAL: LESSGREATER, // >=
+	PLUS:     SUM,         // +
+	MINUS:    SUM,         // -
 	IN:       PRODUCT,     // IN
 	LPAREN:   CALL,        // ()
 }
@@ -45,6 +50,10 @@ type Parser struct {
 	curToken       Token                       // 当前正在检测的词法单元，决定下一步该做什么
 	peekToken      Token                       // 下一个需要检测的词法单元
 	errors         []string                    // 记录语法解析过程中的错误
+	details        []ParseError                // 带有位置的错误，与errors一一对应
+	positions      map[Node]int                // 表达式节点在输入中的起始位置
+	schema         Schema                      // 标识符的类型声明，类型检测时使用
+	mixedArrays    bool                        // 是否允许元素类型不同的数组
 	prefixParseFns map[TokenType]prefixParseFn // 前缀表达式处理函数
 	infixParseFns  map[TokenType]infixParseFn  // 中缀表达式处理函数
 }
@@ -54,6 +63,7 @@ func NewParser(l *Lexer) *Parser {
 	p := &Parser{
 		l:              l,
 		errors:         []string{},
+		positions:      make(map[Node]int),
 		prefixParseFns: make(map[TokenType]prefixParseFn),
 		infixParseFns:  make(map[TokenType]infixParseFn),
 	}
@@ -61,20 +71,27 @@ func NewParser(l *Lexer) *Parser {
 	// 注册表达式解析函数, 前缀运算符
 	p.registerPrefix(IDENT, p.parseIdentifier)         // abc
 	p.registerPrefix(INT, p.parseInteger)              // 123
+	p.registerPrefix(FLOAT, p.parseFloat)              // 1.5
 	p.registerPrefix(STRING, p.parseString)            // "abc"
+	p.registerPrefix(TIME, p.parseTime)                // @2024-01-01T00:00:00Z
+	p.registerPrefix(DURATION, p.parseDuration)        // 7d
 	p.registerPrefix(TRUE, p.parseBoolean)             // true
 	p.registerPrefix(FALSE, p.parseBoolean)            // false
 	p.registerPrefix(LBRACKET, p.parseArray)           // [
 	p.registerPrefix(BANG, p.presePrefixExpression)    // !
+	p.registerPrefix(MINUS, p.parseNegative)           // -5 -1.5 -7d
 	p.registerPrefix(LPAREN, p.parseGroupedExpression) // (
 
 	// 注册表达式解析函数, 中缀运算符
 	p.registerInfix(EQ, p.parseInfixExpression)       // ==
 	p.registerInfix(NOT_EQ, p.parseInfixExpression)   // !=
+	p.registerInfix(REG, p.parseInfixExpression)      // ~=
 	p.registerInfix(LT, p.parseInfixExpression)       // <
 	p.registerInfix(LT_EQUAL, p.parseInfixExpression) // <=
 	p.registerInfix(GT, p.parseInfixExpression)       // >
 	p.registerInfix(GT_EQUAL, p.parseInfixExpression) // >=
+	p.registerInfix(PLUS, p.parseInfixExpression)     // +
+	p.registerInfix(MINUS, p.parseInfixExpression)    // -
 	p.registerInfix(IN, p.parseInfixExpression)       // IN
 	p.registerInfix(AND, p.parseInfixExpression)      // AND
 	p.registerInfix(OR, p.parseInfixExpression)       // OR
@@ -86,6 +103,17 @@ func NewParser(l *Lexer) *Parser {
 	return p
 }
 
+// SetSchema 设置标识符的类型声明，需要在ParseProgram之前调用
+// 未声明的标识符在类型检测时可以匹配任意类型
+func (p *Parser) SetSchema(schema Schema) {
+	p.schema = schema
+}
+
+// AllowMixedArrays 允许数组中的元素类型不同，这样的数组类型为ARRAY_OBJ，需要在ParseProgram之前调用
+func (p *Parser) AllowMixedArrays() {
+	p.mixedArrays = true
+}
+
 // ParseProgram
 func (p *Parser) ParseProgram() *Program {
 	program := &Program{}
@@ -102,6 +130,16 @@ func (p *Parser) ParseProgram() *Program {
 	return program
 }
 
+// Compile 解析input并进行类型检测，有错误时返回error
+func Compile(input string) (*Program, error) {
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("compile %q: %s", input, strings.Join(p.Errors(), "; "))
+	}
+	return program, nil
+}
+
 // 解析表达式
 func (p *Parser) parseExpression(precedence int) Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
@@ -109,7 +147,8 @@ func (p *Parser) parseExpression(precedence int) Expression {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
-	leftExp := prefix()
+	start := p.curToken.Pos
+	leftExp := p.setPos(prefix(), start)
 	// 向下递归
 	for !p.peekTokenIs(EOF) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -117,7 +156,7 @@ func (p *Parser) parseExpression(precedence int) Expression {
 			return leftExp
 		}
 		p.nextToken()
-		leftExp = infix(leftExp)
+		leftExp = p.setPos(infix(leftExp), start)
 	}
 	return leftExp
 }
@@ -167,73 +206,86 @@ func (p *Parser) parseInteger() Expression {
 	lit := &Integer{}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+// 解析浮点数字面量
+func (p *Parser) parseFloat() Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorAt(p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+	return &Float{Value: value}
+}
+
+// 解析负数字面量，- 后面只能是整数、浮点数或者时间间隔字面量，-x 不是合法的表达式
+func (p *Parser) parseNegative() Expression {
+	switch p.peekToken.Type {
+	case INT, FLOAT, DURATION:
+	default:
+		p.errorAt(p.curToken.Pos, "expected number or duration after -, got %s instead", p.peekToken.Type)
+		return nil
+	}
+	p.nextToken()
+	// 带上符号一起解析，-9223372036854775808 不会溢出
+	p.curToken.Literal = "-" + p.curToken.Literal
+	return p.prefixParseFns[p.curToken.Type]()
+}
+
 // 解析字符串字面量
 func (p *Parser) parseString() Expression {
 	return &String{Value: p.curToken.Literal}
 }
 
+// 解析时间字面量，没有时区时使用UTC
+func (p *Parser) parseTime() Expression {
+	t, err := parseTime(p.curToken.Literal, time.UTC)
+	if err != nil {
+		p.errorAt(p.curToken.Pos, "could not parse %q as time: %s", p.curToken.Literal, err)
+		return nil
+	}
+	return &Time{Value: t}
+}
+
+// 解析时间间隔字面量
+func (p *Parser) parseDuration() Expression {
+	d, err := parseDuration(p.curToken.Literal)
+	if err != nil {
+		p.errorAt(p.curToken.Pos, "could not parse %q as duration: %s", p.curToken.Literal, err)
+		return nil
+	}
+	return &Duration{Value: d}
+}
+
 // 解析bool类型的字面量
 func (p *Parser) parseBoolean() Expression {
 	return &Boolean{Value: p.curTokenIs(TRUE)}
 }
 
+// 解析数组字面量，元素可以是任意表达式, [] [1, 2] [a, "b"] [[1], [2, 3]]
 func (p *Parser) parseArray() Expression {
-	// empty array
-	if p.peekTokenIs(RBRACKET) {
+	elems := []Expression{}
+	for !p.peekTokenIs(RBRACKET) {
 		p.nextToken()
-		p.errors = append(p.errors, "empty array is not allowed")
-		return nil
-	}
-	// array type
-	switch {
-	case p.peekTokenIs(STRING):
-		arr := &ArrayString{
-			Value: make([]string, 0),
+		elem := p.parseExpression(LOWEST)
+		if elem == nil {
+			return nil
 		}
-		for !p.peekTokenIs(RBRACKET) {
-			p.nextToken()
-			arr.Value = append(arr.Value, p.curToken.Literal)
-			if p.peekTokenIs(COMMA) {
-				p.nextToken()
-			} else {
-				p.expectPeek(RBRACKET)
-				break
-			}
+		elems = append(elems, elem)
+		if !p.peekTokenIs(COMMA) {
+			break
 		}
-		return arr
-	case p.peekTokenIs(INT):
-		arr := &ArrayInteger{
-			Value: make([]int64, 0),
-		}
-		for !p.peekTokenIs(RBRACKET) {
-			p.nextToken()
-			i, e := strconv.ParseInt(p.curToken.Literal, 10, 64)
-			if e != nil {
-				p.errors = append(p.errors,
-					fmt.Sprintf("the data type of the array is not a integer, err(%s)", e))
-				return nil
-			}
-			arr.Value = append(arr.Value, i)
-			if p.peekTokenIs(COMMA) {
-				p.nextToken()
-			} else {
-				p.expectPeek(RBRACKET)
-				break
-			}
-		}
-		return arr
-	default:
-		p.errors = append(p.errors, "unknow array type")
+		p.nextToken()
+	}
+	if !p.expectPeek(RBRACKET) {
 		return nil
 	}
+	return NewArray(elems...)
 }
 
 func (p *Parser) parseGroupedExpression() Expression {
@@ -285,8 +337,7 @@ func (p *Parser) expectPeek(t TokenType) bool {
 }
 
 func (p *Parser) peekError(t TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) registerPrefix(tokenType TokenType, fn prefixParseFn) {
@@ -298,8 +349,7 @@ func (p *Parser) registerInfix(tokenType TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t TokenType) {
-	p.errors = append(p.errors,
-		fmt.Sprintf("no prefix parse function for %s found", t))
+	p.errorAt(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) nextToken() {
@@ -319,3 +369,67 @@ func (p *Parser) peekTokenIs(t TokenType) bool {
 func (p *Parser) Errors() []string {
 	return p.errors
 }
+
+// ParseError 带有位置的语法或类型错误
+type ParseError struct {
+	Pos    int // 字节偏移，未知时为-1
+	Line   int // 从1开始的行号
+	Column int // 从1开始的列号
+	Msg    string
+}
+
+func (e ParseError) Error() string {
+	if e.Pos < 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// ErrorDetails 返回带有位置的错误，与Errors()一一对应
+func (p *Parser) ErrorDetails() []ParseError {
+	ret := make([]ParseError, 0, len(p.details))
+	for _, e := range p.details {
+		if e.Pos >= 0 && p.l != nil {
+			e.Line, e.Column = lineColumn(p.l.input, e.Pos)
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// errorAt 记录一个位于pos的错误
+func (p *Parser) errorAt(pos int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	p.errors = append(p.errors, msg)
+	p.details = append(p.details, ParseError{Pos: pos, Msg: msg})
+}
+
+// setPos 记录表达式节点的起始位置
+func (p *Parser) setPos(exp Expression, pos int) Expression {
+	if exp != nil && p.positions != nil {
+		p.positions[exp] = pos
+	}
+	return exp
+}
+
+// Pos 返回ParseProgram解析出的节点在输入中的起始位置，未知时返回-1
+func (p *Parser) Pos(node Node) int {
+	return p.nodePos(node)
+}
+
+// nodePos 返回节点的起始位置，未知时返回-1
+func (p *Parser) nodePos(node Node) int {
+	if pos, ok := p.positions[node]; ok {
+		return pos
+	}
+	return -1
+}
+
+// lineColumn 将字节偏移转换为从1开始的行号和列号
+func lineColumn(input string, pos int) (int, int) {
+	if pos > len(input) {
+		pos = len(input)
+	}
+	line := 1 + strings.Count(input[:pos], "\n")
+	return line, pos - strings.LastIndex(input[:pos], "\n")
+}
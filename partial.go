@@ -0,0 +1,155 @@
+package conditions
+
+// PartialEval 在只知道部分变量的情况下对表达式求值
+//
+// 所有只依赖env中已绑定标识符的子表达式都会被折叠成字面量，
+// 未绑定的标识符保持原样，返回一个剩余的Program；
+// 如果结果已经不依赖未知的标识符，返回的Program.Expression为*Boolean
+func PartialEval(program *Program, env *Environment) (*Program, error) {
+	exp, err := partialEval(program.Expression, env)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{Expression: exp}, nil
+}
+
+func partialEval(node Expression, env *Environment) (Expression, error) {
+	switch node := node.(type) {
+	case *Identifier:
+		if val, ok := env.Get(node.Value); ok {
+			if exp, ok := val.(Expression); ok {
+				return exp, nil
+			}
+		}
+		return node, nil
+	case *PrefixExpresion:
+		right, err := partialEval(node.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		if isLiteral(right) {
+			return foldExpression(&PrefixExpresion{Operator: node.Operator, Right: right}, env)
+		}
+		return &PrefixExpresion{Operator: node.Operator, Right: right}, nil
+	case *InfixExpression:
+		left, err := partialEval(node.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		right, err := partialEval(node.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		exp := &InfixExpression{Left: left, Operator: node.Operator, Right: right}
+		if isLiteral(left) && isLiteral(right) {
+			return foldExpression(exp, env)
+		}
+		switch node.Operator {
+		case AND:
+			return partialLogical(exp, false), nil
+		case OR:
+			return partialLogical(exp, true), nil
+		}
+		return exp, nil
+	case *Array:
+		elems := make([]Expression, 0, len(node.Elements))
+		for _, e := range node.Elements {
+			elem, err := partialEval(e, env)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		return NewArray(elems...), nil
+	case *CallExpression:
+		if _, ok := loopVariable(node); ok {
+			return partialQuantifier(node, env)
+		}
+		exp := &CallExpression{Function: node.Function, Arguments: make([]Expression, 0, len(node.Arguments))}
+		known := true
+		for _, a := range node.Arguments {
+			arg, err := partialEval(a, env)
+			if err != nil {
+				return nil, err
+			}
+			known = known && isLiteral(arg)
+			exp.Arguments = append(exp.Arguments, arg)
+		}
+		if known {
+			return foldExpression(exp, env)
+		}
+		return exp, nil
+	}
+	return node, nil
+}
+
+// partialQuantifier 只对量词的数组部分求值，谓词中的循环变量不能被env中的同名标识符替换，
+// 谓词不再引用其它未知标识符时整体求值
+func partialQuantifier(node *CallExpression, env *Environment) (Expression, error) {
+	arr, err := partialEval(node.Arguments[0], env)
+	if err != nil {
+		return nil, err
+	}
+	exp := &CallExpression{Function: node.Function, Arguments: []Expression{arr, node.Arguments[1], node.Arguments[2]}}
+	for _, name := range Variables(&Program{Expression: exp}) {
+		if _, ok := env.Get(name); !ok {
+			return exp, nil
+		}
+	}
+	return foldExpression(exp, env)
+}
+
+// partialLogical 处理只有一侧已知的 && 和 ||
+// short为true表示 ||，已知一侧为真时结果为真；short为false表示 &&，已知一侧为假时结果为假
+func partialLogical(exp *InfixExpression, short bool) Expression {
+	for _, side := range [2]struct{ known, other Expression }{
+		{exp.Left, exp.Right},
+		{exp.Right, exp.Left},
+	} {
+		if !isLiteral(side.known) {
+			continue
+		}
+		if objectToNativeBoolean(side.known.(Object)) == short {
+			return nativeBoolToBooleanObject(short)
+		}
+		// true && x 的结果是x转换为bool，x不一定是bool时不能化简为x
+		if isBooleanExpression(side.other) {
+			return side.other
+		}
+		return exp
+	}
+	return exp
+}
+
+// foldExpression 对只包含字面量的表达式求值，并转化为字面量
+func foldExpression(exp Expression, env *Environment) (Expression, error) {
+	if call, ok := exp.(*CallExpression); ok && volatileBuiltins[call.Function.String()] {
+		return exp, nil
+	}
+	obj := Eval(exp, env)
+	if err, ok := obj.(*Error); ok {
+		return nil, newError("partial eval %s: %s", exp.String(), err.Message)
+	}
+	lit, ok := obj.(Expression)
+	if !ok || !isLiteral(lit) {
+		return exp, nil
+	}
+	return lit, nil
+}
+
+// isLiteral 判断一个表达式是否已经是字面量
+func isLiteral(exp Expression) bool {
+	switch exp := exp.(type) {
+	case *Integer, *Float, *String, *Boolean, *Time, *Duration, *IP:
+		return true
+	case *Array:
+		// 元素都是字面量的数组
+		for _, elem := range exp.Elements {
+			if !isLiteral(elem) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
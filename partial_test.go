@@ -0,0 +1,34 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`tenant == "a" && age >= 18`, `(age >= 18)`},
+		{`tenant == "b" && age >= 18`, `false`},
+		{`tenant == "a" || age >= 18`, `true`},
+		{`len(tenant) > 0 && (plan == "pro" || age > level)`, `((plan == "pro") || (age > 3))`},
+		{`!(level >= 3) || age < 10`, `(age < 10)`},
+		{`tenant >= "aa" || age < 10`, `(age < 10)`},
+		{`tenant <= "b" && age < 10`, `(age < 10)`},
+	}
+	for _, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		program := p.ParseProgram()
+		assert.Equal(t, 0, len(p.Errors()), tt.input)
+
+		env := NewEnvironment()
+		env.Set("tenant", &String{Value: "a"})
+		env.Set("level", &Integer{Value: 3})
+		residual, err := PartialEval(program, env)
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.expected, residual.String(), tt.input)
+	}
+}
@@ -0,0 +1,160 @@
+package conditions
+
+import "fmt"
+
+// quantifier 数组上的量词，例如 all(items, x, x > 0)
+// 量词是宏而不是内置函数：谓词对每个元素惰性求值，循环变量只在谓词中可见
+type quantifier struct {
+	returnType ObjectType
+	// reduce 根据谓词的结果更新计数，返回true表示可以提前结束
+	reduce func(matched bool, count *int64) bool
+	result func(count int64) Object
+}
+
+var quantifiers = map[string]quantifier{
+	// all(items, x, pred) 所有元素都满足谓词，空数组为true，count记录不满足的元素
+	"all": {
+		returnType: BOOLEAN_OBJ,
+		reduce:     func(matched bool, count *int64) bool { return countIf(!matched, count) },
+		result: func(count int64) Object {
+			return nativeBoolToBooleanObject(count == 0)
+		},
+	},
+	// any(items, x, pred) 至少一个元素满足谓词，空数组为false
+	"any": {
+		returnType: BOOLEAN_OBJ,
+		reduce:     func(matched bool, count *int64) bool { return countIf(matched, count) },
+		result: func(count int64) Object {
+			return nativeBoolToBooleanObject(count != 0)
+		},
+	},
+	// none(items, x, pred) 没有元素满足谓词，空数组为true
+	"none": {
+		returnType: BOOLEAN_OBJ,
+		reduce:     func(matched bool, count *int64) bool { return countIf(matched, count) },
+		result: func(count int64) Object {
+			return nativeBoolToBooleanObject(count == 0)
+		},
+	},
+	// count(items, x, pred) 满足谓词的元素个数
+	"count": {
+		returnType: INTEGER_OBJ,
+		reduce: func(matched bool, count *int64) bool {
+			countIf(matched, count)
+			return false
+		},
+		result: func(count int64) Object {
+			return &Integer{Value: count}
+		},
+	},
+}
+
+// countIf cond为true时计数加一，返回cond
+func countIf(cond bool, count *int64) bool {
+	if cond {
+		*count++
+	}
+	return cond
+}
+
+// loopVariable 返回量词的循环变量，不是量词时返回false
+func loopVariable(call *CallExpression) (string, bool) {
+	if _, ok := quantifiers[call.Function.String()]; !ok || len(call.Arguments) != 3 {
+		return "", false
+	}
+	ident, ok := call.Arguments[1].(*Identifier)
+	if !ok {
+		return "", false
+	}
+	return ident.Value, true
+}
+
+// evalQuantifier 在嵌套的作用域中依次绑定循环变量并对谓词求值
+func evalQuantifier(q quantifier, call *CallExpression, env *Environment) Object {
+	name := call.Function.String()
+	if len(call.Arguments) != 3 {
+		return newError("wrong number of argument. got=%d, want=3", len(call.Arguments))
+	}
+	variable, ok := loopVariable(call)
+	if !ok {
+		return newError("second argument to `%s` must be an identifier, got %s", name, call.Arguments[1].String())
+	}
+	arr := Eval(call.Arguments[0], env)
+	if isError(arr) {
+		return arr
+	}
+	elems, ok := arr.(*Array)
+	if !ok {
+		return newError("first argument to `%s` not supported, got %s", name, arr.ObjectType())
+	}
+	scope := NewEnclosedEnvironment(env)
+	var count int64
+	for _, elem := range elems.Elements {
+		scope.Set(variable, elem.(Object))
+		obj := Eval(call.Arguments[2], scope)
+		if isError(obj) {
+			return obj
+		}
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return newError("predicate of `%s` must be a boolean expression, got %s", name, obj.ObjectType())
+		}
+		if q.reduce(b.Value, &count) {
+			break
+		}
+	}
+	return q.result(count)
+}
+
+// checkQuantifier 检测量词的类型，循环变量的类型为数组的元素类型
+func (p *Parser) checkQuantifier(q quantifier, n *CallExpression) ObjectType {
+	name := n.Function.String()
+	if len(n.Arguments) != 3 {
+		p.errorAt(p.nodePos(n), "CallExpression %s args len error, expect 3, got %d", name, len(n.Arguments))
+		return ERROR_OBJ
+	}
+	variable, ok := loopVariable(n)
+	if !ok {
+		p.errorAt(p.nodePos(n.Arguments[1]), "CallExpression %s loop variable must be an identifier, got %s",
+			name, n.Arguments[1].String())
+		return ERROR_OBJ
+	}
+	arr := p.CheckType(n.Arguments[0])
+	if arr == ERROR_OBJ {
+		return ERROR_OBJ
+	}
+	if arr == IDENT_OBJ {
+		arr = ARRAY_OBJ
+	}
+	elem, ok := ElementType(arr)
+	if !ok {
+		p.errorAt(p.nodePos(n.Arguments[0]), "CallExpression %s expect array, got %s", name, arr)
+		return ERROR_OBJ
+	}
+	// 循环变量只在谓词中可见
+	outer := p.schema
+	p.schema = make(Schema, len(outer)+1)
+	for k, v := range outer {
+		p.schema[k] = v
+	}
+	p.schema[variable] = elem
+	pred := p.CheckType(n.Arguments[2])
+	p.schema = outer
+	if pred == ERROR_OBJ {
+		return ERROR_OBJ
+	}
+	if pred != BOOLEAN_OBJ && pred != IDENT_OBJ {
+		p.errorAt(p.nodePos(n.Arguments[2]), "CallExpression %s predicate expect BOOLEAN, got %s", name, pred)
+		return ERROR_OBJ
+	}
+	return q.returnType
+}
+
+// quantifierSignatures 量词的签名，用于补全提示
+func quantifierSignatures() map[string]string {
+	ret := make(map[string]string, len(quantifiers))
+	for name, q := range quantifiers {
+		ret[name] = fmt.Sprintf("%s(ARRAY, IDENT, BOOLEAN) %s", name, q.returnType)
+	}
+	return ret
+}
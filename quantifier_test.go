@@ -0,0 +1,89 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantifiers(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("items", NewIntegerArray(1, 2, 3))
+	env.Set("tags", NewStringArray("vip_gold", "new"))
+	env.Set("empty", NewIntegerArray())
+	env.Set("x", &Integer{Value: 100})
+	for input, expect := range map[string]Object{
+		`all(items, x, x > 0)`:                               boolTrue,
+		`all(items, x, x > 1)`:                               boolFalse,
+		`any(tags, t, startsWith(t, "vip"))`:                 boolTrue,
+		`any(tags, t, endsWith(t, "vip"))`:                   boolFalse,
+		`none(tags, t, endsWith(t, "old"))`:                  boolFalse,
+		`count(items, i, i >= 2)`:                            &Integer{Value: 2},
+		`count(items, i, i >= 2) == 2`:                       boolTrue,
+		`any(items, i, all(items, j, i >= j))`:               boolTrue,
+		`all(items, i, i < x)`:                               boolTrue,
+		`all(items, x, x < 3) || x == 100`:                   boolTrue,
+		`all(empty, i, false) && !any(empty, i, true)`:       boolTrue,
+		`none(empty, i, true) && count(empty, i, true) == 0`: boolTrue,
+	} {
+		program, err := Compile(input)
+		if assert.Nil(t, err, input) {
+			assert.Equal(t, expect, Eval(program, env), input)
+		}
+	}
+
+	// 谓词的错误以及短路求值
+	env.Set("patterns", NewStringArray("a", "("))
+	program, _ := Compile(`any(patterns, p, regexp("a", p))`)
+	assert.Equal(t, boolTrue, Eval(program, env))
+	program, _ = Compile(`all(patterns, p, regexp("a", p))`)
+	assert.IsType(t, &Error{}, Eval(program, env))
+	program, _ = Compile(`all(items, i, unknown)`)
+	assert.Equal(t, &Error{Message: "identifier not found: unknown"}, Eval(program, env))
+}
+
+func TestQuantifierTypeCheck(t *testing.T) {
+	schema := Schema{"items": ARRAY_INTEGER_OBJ, "name": STRING_OBJ}
+	for input, expect := range map[string]string{
+		`all(items, x, x + 1)`:    "CallExpression all predicate expect BOOLEAN, got INTEGER",
+		`all(items, 1, true)`:     "CallExpression all loop variable must be an identifier, got 1",
+		`any(items, x)`:           "CallExpression any args len error, expect 3, got 2",
+		`count(name, c, true)`:    "CallExpression count expect array, got STRING",
+		`any(items, x, x == "a")`: "InfixExpression <exp>==<exp> right expect INTEGER, got STRING",
+	} {
+		p := NewParser(NewLexer(input))
+		p.SetSchema(schema)
+		p.ParseProgram()
+		if assert.NotEmpty(t, p.Errors(), input) {
+			assert.Contains(t, p.Errors()[0], expect, input)
+		}
+	}
+	p := NewParser(NewLexer(`count(items, x, x > 1) > 1 && all(items, x, x > 0)`))
+	p.SetSchema(schema)
+	program := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+	assert.Equal(t, BOOLEAN_OBJ, p.CheckType(program))
+}
+
+func TestQuantifierVariables(t *testing.T) {
+	program, err := Compile(`all(items, x, x > min) && x > 1`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"items", "min", "x"}, Variables(program))
+	}
+
+	env := NewEnvironment()
+	env.Set("items", NewIntegerArray(1, 2))
+	env.Set("x", &Integer{Value: 0})
+	residual, err := PartialEval(program, env)
+	assert.Nil(t, err)
+	assert.Equal(t, `false`, residual.String())
+	residual, err = PartialEval(&Program{Expression: program.Expression.(*InfixExpression).Left}, env)
+	assert.Nil(t, err)
+	assert.Equal(t, `all([1,2], x, (x > min))`, residual.String())
+
+	env.Set("min", &Integer{Value: 0})
+	program, _ = Compile(`any(items, x, x > min)`)
+	residual, err = PartialEval(program, env)
+	assert.Nil(t, err)
+	assert.Equal(t, `true`, residual.String())
+}
@@ -0,0 +1,433 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultClauseLimit 展开为范式时默认的最大子句数量
+const DefaultClauseLimit = 4096
+
+// ErrUnknown 寻找见证值超出了搜索范围，无法判断是否可满足
+var ErrUnknown = errors.New("satisfiability unknown: witness search limit exceeded")
+
+// Satisfiable 判断在schema下是否存在使program为true的环境，存在时返回一个见证环境
+//
+// 支持整数的范围、字符串的相等和长度、in集合以及布尔逻辑，
+// 其它写法(例如两个标识符之间的比较)会返回error；超出见证值的搜索范围时返回ErrUnknown
+func Satisfiable(program *Program, schema Schema) (bool, *Environment, error) {
+	exp := negationNormalForm(booleanIdentifiers(program.Expression, schema), false)
+	clauses, err := disjunctiveClauses(exp, DefaultClauseLimit)
+	if err != nil {
+		return false, nil, err
+	}
+	vars := Variables(program)
+	for _, name := range vars {
+		switch t, ok := schema[name]; {
+		case !ok:
+			return false, nil, fmt.Errorf("identifier %s not declared in schema", name)
+		case t != INTEGER_OBJ && t != STRING_OBJ && t != BOOLEAN_OBJ:
+			return false, nil, fmt.Errorf("identifier %s: unsupported type %s", name, t)
+		}
+	}
+	unknown := false
+	for _, clause := range clauses {
+		domains := make(map[string]*domain, len(vars))
+		for _, name := range vars {
+			domains[name] = newDomain(schema[name])
+		}
+		ok, err := satisfyClause(clause, domains)
+		if err != nil {
+			return false, nil, err
+		}
+		if !ok {
+			continue
+		}
+		env, ok, err := witness(domains)
+		if err != nil {
+			unknown = true
+			continue
+		}
+		if ok {
+			return true, env, nil
+		}
+	}
+	if unknown {
+		return false, nil, ErrUnknown
+	}
+	return false, nil, nil
+}
+
+// booleanIdentifiers 将逻辑运算中声明为bool的标识符改写为 x == true，
+// 使 ! 可以下推到这些标识符上，例如 !!vip => vip == true
+func booleanIdentifiers(exp Expression, schema Schema) Expression {
+	switch n := exp.(type) {
+	case *Identifier:
+		if schema[n.Value] == BOOLEAN_OBJ {
+			return &InfixExpression{Left: n, Operator: EQ, Right: boolTrue}
+		}
+	case *PrefixExpresion:
+		if n.Operator == BANG {
+			return &PrefixExpresion{Operator: BANG, Right: booleanIdentifiers(n.Right, schema)}
+		}
+	case *InfixExpression:
+		if n.Operator == AND || n.Operator == OR {
+			return &InfixExpression{
+				Left:     booleanIdentifiers(n.Left, schema),
+				Operator: n.Operator,
+				Right:    booleanIdentifiers(n.Right, schema),
+			}
+		}
+	}
+	return exp
+}
+
+// Overlap 判断a和b是否可能同时为true，可能时返回见证环境
+func Overlap(a, b *Program, schema Schema) (bool, *Environment, error) {
+	return Satisfiable(&Program{Expression: &InfixExpression{
+		Left:     a.Expression,
+		Operator: AND,
+		Right:    b.Expression,
+	}}, schema)
+}
+
+// Implies 判断a为true时b是否一定为true
+func Implies(a, b *Program, schema Schema) (bool, error) {
+	sat, _, err := Satisfiable(&Program{Expression: &InfixExpression{
+		Left:     a.Expression,
+		Operator: AND,
+		Right:    &PrefixExpresion{Operator: BANG, Right: b.Expression},
+	}}, schema)
+	return !sat && err == nil, err
+}
+
+// domain 一个标识符在子句中的取值范围
+type domain struct {
+	typ      ObjectType
+	lo, hi   int64               // 整数的范围，或者字符串的长度范围
+	allowed  map[string]struct{} // 取值必须在集合中，nil表示不限制
+	excluded map[string]struct{} // 取值不能在集合中
+	ints     map[string]int64    // 整数集合中值的原始形式
+}
+
+func newDomain(typ ObjectType) *domain {
+	d := &domain{typ: typ, lo: math.MinInt64, hi: math.MaxInt64, excluded: map[string]struct{}{}, ints: map[string]int64{}}
+	if typ == STRING_OBJ {
+		d.lo = 0
+	}
+	return d
+}
+
+func (d *domain) allow(values ...Object) {
+	set := map[string]struct{}{}
+	for _, v := range values {
+		key := d.key(v)
+		if d.allowed == nil {
+			set[key] = struct{}{}
+		} else if _, ok := d.allowed[key]; ok {
+			set[key] = struct{}{}
+		}
+	}
+	d.allowed = set
+}
+
+func (d *domain) exclude(values ...Object) {
+	for _, v := range values {
+		d.excluded[d.key(v)] = struct{}{}
+	}
+}
+
+func (d *domain) key(v Object) string {
+	if i, ok := v.(*Integer); ok {
+		key := integerKey(i.Value)
+		d.ints[key] = i.Value
+		return key
+	}
+	return objectKey(v)
+}
+
+func (d *domain) restrict(operator TokenType, n int64) {
+	switch operator {
+	case LT:
+		if n == math.MinInt64 {
+			d.hi, d.lo = math.MinInt64, math.MaxInt64
+			return
+		}
+		d.hi = min64(d.hi, n-1)
+	case LT_EQUAL:
+		d.hi = min64(d.hi, n)
+	case GT:
+		if n == math.MaxInt64 {
+			d.hi, d.lo = math.MinInt64, math.MaxInt64
+			return
+		}
+		d.lo = max64(d.lo, n+1)
+	case GT_EQUAL:
+		d.lo = max64(d.lo, n)
+	}
+}
+
+// satisfyClause 将子句中的原子条件应用到取值范围上，出现矛盾的字面量时返回false
+func satisfyClause(clause []Expression, domains map[string]*domain) (bool, error) {
+	for _, atom := range clause {
+		if b, ok := atom.(*Boolean); ok {
+			if !b.Value {
+				return false, nil
+			}
+			continue
+		}
+		if err := applyAtom(atom, domains); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func applyAtom(atom Expression, domains map[string]*domain) error {
+	switch n := atom.(type) {
+	case *Identifier:
+		return applyComparison(domains[n.Value], n, EQ, boolTrue)
+	case *PrefixExpresion:
+		switch right := n.Right.(type) {
+		case *Identifier:
+			return applyComparison(domains[right.Value], right, EQ, boolFalse)
+		case *InfixExpression:
+			if right.Operator == IN {
+				return applyIn(right, domains, true)
+			}
+		}
+	case *InfixExpression:
+		if n.Operator == IN {
+			return applyIn(n, domains, false)
+		}
+		if _, ok := negatedOperators[n.Operator]; !ok {
+			break
+		}
+		left, operator, right := n.Left, n.Operator, n.Right
+		if _, ok := right.(*Identifier); ok {
+			left, right, operator = right, left, flippedOperators[operator]
+		}
+		if call, ok := left.(*CallExpression); ok && call.Function.String() == "len" && len(call.Arguments) == 1 {
+			ident, isIdent := call.Arguments[0].(*Identifier)
+			size, isInt := right.(*Integer)
+			if !isIdent || !isInt || domains[ident.Value].typ != STRING_OBJ {
+				break
+			}
+			return applyLength(domains[ident.Value], operator, size.Value)
+		}
+		ident, ok := left.(*Identifier)
+		if !ok || !isLiteral(right) {
+			break
+		}
+		return applyComparison(domains[ident.Value], ident, operator, right.(Object))
+	}
+	return fmt.Errorf("unsupported condition %s", atom.String())
+}
+
+func applyComparison(d *domain, ident *Identifier, operator TokenType, value Object) error {
+	if value.ObjectType() != d.typ {
+		return fmt.Errorf("%s is %s, cannot compare with %s", ident.Value, d.typ, value.ObjectType())
+	}
+	switch operator {
+	case EQ:
+		d.allow(value)
+	case NOT_EQ:
+		d.exclude(value)
+	default:
+		switch v := value.(type) {
+		case *Integer:
+			d.restrict(operator, v.Value)
+		default:
+			return fmt.Errorf("unsupported operator %s for %s", operator, d.typ)
+		}
+	}
+	return nil
+}
+
+func applyLength(d *domain, operator TokenType, n int64) error {
+	switch operator {
+	case EQ:
+		d.restrict(GT_EQUAL, n)
+		d.restrict(LT_EQUAL, n)
+	case NOT_EQ:
+		return fmt.Errorf("unsupported length inequality")
+	default:
+		d.restrict(operator, n)
+	}
+	return nil
+}
+
+func applyIn(n *InfixExpression, domains map[string]*domain, negate bool) error {
+	ident, ok := n.Left.(*Identifier)
+	if !ok {
+		return fmt.Errorf("unsupported condition %s", n.String())
+	}
+	d := domains[ident.Value]
+	arr, ok := n.Right.(*Array)
+	if !ok || !isLiteral(arr) {
+		return fmt.Errorf("unsupported condition %s", n.String())
+	}
+	if _, ok := cidrList(arr); ok {
+		return fmt.Errorf("unsupported condition %s: CIDR list", n.String())
+	}
+	var values []Object
+	for _, elem := range arr.Elements {
+		if elem.(Object).ObjectType() != d.typ {
+			return fmt.Errorf("%s is %s, cannot be in %s", ident.Value, d.typ, n.Right.String())
+		}
+		values = append(values, elem.(Object))
+	}
+	if negate {
+		d.exclude(values...)
+	} else {
+		d.allow(values...)
+	}
+	return nil
+}
+
+// witness 为每个标识符选择一个满足取值范围的值
+func witness(domains map[string]*domain) (*Environment, bool, error) {
+	env := NewEnvironment()
+	for name, d := range domains {
+		v, ok, err := d.pick()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		env.Set(name, v)
+	}
+	return env, true, nil
+}
+
+// pick 选择一个满足取值范围的值，不存在时返回false，超出搜索范围时返回ErrUnknown
+func (d *domain) pick() (Object, bool, error) {
+	if d.lo > d.hi {
+		return nil, false, nil
+	}
+	if d.allowed != nil {
+		keys := make([]string, 0, len(d.allowed))
+		for key := range d.allowed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if v, ok := d.fromKey(key); ok {
+				return v, true, nil
+			}
+		}
+		return nil, false, nil
+	}
+	switch d.typ {
+	case BOOLEAN_OBJ:
+		for _, b := range []*Boolean{boolTrue, boolFalse} {
+			if _, ok := d.excluded[objectKey(b)]; !ok {
+				return b, true, nil
+			}
+		}
+	case INTEGER_OBJ:
+		// 从最接近0的值开始向两侧尝试，被排除的值是有限的
+		start := d.lo
+		if d.lo <= 0 && 0 <= d.hi {
+			start = 0
+		} else if d.lo == math.MinInt64 {
+			start = d.hi
+		}
+		for i := int64(0); i <= int64(len(d.excluded)); i++ {
+			candidates := []int64{start + i, start - i}
+			if i == 0 {
+				candidates = candidates[:1]
+			}
+			for _, v := range candidates {
+				if v < d.lo || v > d.hi {
+					continue
+				}
+				if _, ok := d.excluded[integerKey(v)]; !ok {
+					return &Integer{Value: v}, true, nil
+				}
+			}
+		}
+	case STRING_OBJ:
+		// 从长度下限开始，依次尝试每种长度下的前len(excluded)+1个字符串
+		// 某个长度下的字符串全部被排除时才尝试下一个长度，因此只有长度超出搜索范围时结果未知
+		length := d.lo
+		for ; length <= d.hi && length <= d.lo+8 && length <= 1<<16; length++ {
+			for i := 0; i <= len(d.excluded); i++ {
+				s, ok := nthString(i, int(length))
+				if !ok {
+					break
+				}
+				if _, ok := d.excluded[stringKey(s)]; !ok {
+					return &String{Value: s}, true, nil
+				}
+			}
+		}
+		if length <= d.hi {
+			return nil, false, ErrUnknown
+		}
+	}
+	return nil, false, nil
+}
+
+func (d *domain) fromKey(key string) (Object, bool) {
+	if _, ok := d.excluded[key]; ok {
+		return nil, false
+	}
+	switch {
+	case strings.HasPrefix(key, "i:"):
+		v := d.ints[key]
+		if v < d.lo || v > d.hi {
+			return nil, false
+		}
+		return &Integer{Value: v}, true
+	case strings.HasPrefix(key, "s:"):
+		s := strings.TrimPrefix(key, "s:")
+		if int64(len(s)) < d.lo || int64(len(s)) > d.hi {
+			return nil, false
+		}
+		return &String{Value: s}, true
+	case key == "b:true":
+		return boolTrue, true
+	case key == "b:false":
+		return boolFalse, true
+	}
+	return nil, false
+}
+
+// nthString 返回长度为length的第i个由小写字母组成的字符串，不存在时返回false
+func nthString(i, length int) (string, bool) {
+	b := []byte(strings.Repeat("a", length))
+	for pos := length - 1; pos >= 0 && i > 0; pos-- {
+		b[pos] = byte('a' + i%26)
+		i /= 26
+	}
+	return string(b), i == 0
+}
+
+// objectKey 字面量的唯一键，带有类型前缀
+func objectKey(v Object) string {
+	switch v := v.(type) {
+	case *Integer:
+		return integerKey(v.Value)
+	case *String:
+		return stringKey(v.Value)
+	case *Boolean:
+		return fmt.Sprintf("b:%v", v.Value)
+	}
+	return fmt.Sprintf("%s:%v", v.ObjectType(), v)
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = Schema{
+	"age":     INTEGER_OBJ,
+	"country": STRING_OBJ,
+	"vip":     BOOLEAN_OBJ,
+	"name":    STRING_OBJ,
+}
+
+func mustCompile(t *testing.T, input string) *Program {
+	program, err := Compile(input)
+	assert.Nil(t, err)
+	return program
+}
+
+func TestSatisfiable(t *testing.T) {
+	tests := []struct {
+		input string
+		sat   bool
+	}{
+		{`age > 18 && age < 20`, true},
+		{`age > 18 && age < 19`, false},
+		{`age in [1, 2, 3] && age != 1 && age != 2 && age != 3`, false},
+		{`country == "CN" && !(country in ["CN", "SG"])`, false},
+		{`!(country in ["CN", "SG"]) && country != ""`, true},
+		{`vip && !vip`, false},
+		{`(vip || age > 60) && !vip && age <= 60`, false},
+		{`len(name) > 2 && len(name) < 4 && name != "aaa"`, true},
+		{`!!vip && !(vip || age > 1)`, false},
+		{`!(!vip && age > 1) && age == 5`, true},
+	}
+	for _, tt := range tests {
+		program := mustCompile(t, tt.input)
+		sat, env, err := Satisfiable(program, testSchema)
+		assert.Nil(t, err, tt.input)
+		assert.Equal(t, tt.sat, sat, tt.input)
+		if sat {
+			// 见证环境必须使表达式为true
+			assert.Equal(t, boolTrue, Eval(program, env), tt.input)
+		}
+	}
+
+	_, _, err := Satisfiable(mustCompile(t, `age > other`), Schema{"age": INTEGER_OBJ, "other": INTEGER_OBJ})
+	assert.NotNil(t, err)
+	_, _, err = Satisfiable(mustCompile(t, `unknown > 1`), testSchema)
+	assert.NotNil(t, err)
+
+	// 超出见证值的搜索范围时结果未知，不能当作不可满足
+	sat, _, err := Satisfiable(mustCompile(t, `len(name) > 70000`), testSchema)
+	assert.Equal(t, ErrUnknown, err)
+	assert.False(t, sat)
+	ok, err := Implies(mustCompile(t, `len(name) > 70000`), mustCompile(t, `vip`), testSchema)
+	assert.Equal(t, ErrUnknown, err)
+	assert.False(t, ok)
+	sat, _, err = Satisfiable(mustCompile(t, `len(name) > 70000 || age == 1`), testSchema)
+	assert.Nil(t, err)
+	assert.True(t, sat)
+}
+
+func TestOverlapAndImplies(t *testing.T) {
+	a := mustCompile(t, `country in ["CN", "SG"] && age >= 18`)
+	b := mustCompile(t, `country == "SG" && age < 21`)
+	c := mustCompile(t, `country == "US"`)
+
+	ok, env, err := Overlap(a, b, testSchema)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, boolTrue, Eval(a, env))
+	assert.Equal(t, boolTrue, Eval(b, env))
+
+	ok, _, err = Overlap(a, c, testSchema)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = Implies(b, mustCompile(t, `age < 30`), testSchema)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = Implies(a, b, testSchema)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
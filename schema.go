@@ -0,0 +1,65 @@
+package conditions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema 标识符的类型声明
+type Schema map[string]ObjectType
+
+// schema文件中的类型名称，[]T为元素类型为T的数组
+var schemaTypes = map[string]ObjectType{
+	"int":      INTEGER_OBJ,
+	"float":    FLOAT_OBJ,
+	"string":   STRING_OBJ,
+	"bool":     BOOLEAN_OBJ,
+	"time":     TIME_OBJ,
+	"duration": DURATION_OBJ,
+	"ip":       IP_OBJ,
+	"[]any":    ARRAY_OBJ, // 元素类型未知的数组
+}
+
+// ParseSchema 解析JSON格式的schema, {"age": "int", "tags": "[]string", "matrix": "[][]int"}
+// 支持的类型有int、float、string、bool、time、duration、ip以及这些类型的数组
+func ParseSchema(data []byte) (Schema, error) {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	schema := make(Schema, len(raw))
+	for name, typ := range raw {
+		t, ok := schemaType(typ)
+		if !ok {
+			return nil, fmt.Errorf("identifier %s: unknown type %q", name, typ)
+		}
+		schema[name] = t
+	}
+	return schema, nil
+}
+
+func schemaType(name string) (ObjectType, bool) {
+	if t, ok := schemaTypes[name]; ok {
+		return t, true
+	}
+	if !strings.HasPrefix(name, "[]") {
+		return "", false
+	}
+	elem, ok := schemaType(name[2:])
+	if !ok || elem == IP_OBJ {
+		return "", false
+	}
+	return ArrayOf(elem), true
+}
+
+// Names 返回所有声明的标识符，已排序
+func (s Schema) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
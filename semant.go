@@ -1,6 +1,10 @@
 package conditions
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 // semantic detection
 // type check
@@ -15,30 +19,57 @@ var prefixProtos = map[TokenType][]ObjectType{
 // infixProtos type check
 var infixProtos = map[TokenType]map[ObjectType]ObjectType{
 	GT: {
-		INTEGER_OBJ: INTEGER_OBJ,
-		STRING_OBJ:  STRING_OBJ,
+		INTEGER_OBJ:  INTEGER_OBJ,
+		FLOAT_OBJ:    FLOAT_OBJ,
+		STRING_OBJ:   STRING_OBJ,
+		TIME_OBJ:     TIME_OBJ,
+		DURATION_OBJ: DURATION_OBJ,
 	},
 	GT_EQUAL: {
-		INTEGER_OBJ: INTEGER_OBJ,
-		STRING_OBJ:  STRING_OBJ,
+		INTEGER_OBJ:  INTEGER_OBJ,
+		FLOAT_OBJ:    FLOAT_OBJ,
+		STRING_OBJ:   STRING_OBJ,
+		TIME_OBJ:     TIME_OBJ,
+		DURATION_OBJ: DURATION_OBJ,
 	},
 	LT: {
-		INTEGER_OBJ: INTEGER_OBJ,
-		STRING_OBJ:  STRING_OBJ,
+		INTEGER_OBJ:  INTEGER_OBJ,
+		FLOAT_OBJ:    FLOAT_OBJ,
+		STRING_OBJ:   STRING_OBJ,
+		TIME_OBJ:     TIME_OBJ,
+		DURATION_OBJ: DURATION_OBJ,
 	},
 	LT_EQUAL: {
-		INTEGER_OBJ: INTEGER_OBJ,
-		STRING_OBJ:  STRING_OBJ,
+		INTEGER_OBJ:  INTEGER_OBJ,
+		FLOAT_OBJ:    FLOAT_OBJ,
+		STRING_OBJ:   STRING_OBJ,
+		TIME_OBJ:     TIME_OBJ,
+		DURATION_OBJ: DURATION_OBJ,
 	},
 	EQ: {
-		INTEGER_OBJ: INTEGER_OBJ,
-		STRING_OBJ:  STRING_OBJ,
-		BOOLEAN_OBJ: BOOLEAN_OBJ,
+		INTEGER_OBJ:  INTEGER_OBJ,
+		FLOAT_OBJ:    FLOAT_OBJ,
+		STRING_OBJ:   STRING_OBJ,
+		BOOLEAN_OBJ:  BOOLEAN_OBJ,
+		TIME_OBJ:     TIME_OBJ,
+		DURATION_OBJ: DURATION_OBJ,
+		IP_OBJ:       IP_OBJ,
 	},
 	NOT_EQ: {
-		INTEGER_OBJ: INTEGER_OBJ,
-		STRING_OBJ:  STRING_OBJ,
-		BOOLEAN_OBJ: BOOLEAN_OBJ,
+		INTEGER_OBJ:  INTEGER_OBJ,
+		FLOAT_OBJ:    FLOAT_OBJ,
+		STRING_OBJ:   STRING_OBJ,
+		BOOLEAN_OBJ:  BOOLEAN_OBJ,
+		TIME_OBJ:     TIME_OBJ,
+		DURATION_OBJ: DURATION_OBJ,
+		IP_OBJ:       IP_OBJ,
+	},
+	REG: {
+		STRING_OBJ: STRING_OBJ,
+	},
+	IN: {
+		// 任意类型都可以在元素类型相同的数组中，单独检测
+		IP_OBJ: ARRAY_STRING_OBJ, // CIDR列表
 	},
 	AND: {
 		BOOLEAN_OBJ: BOOLEAN_OBJ,
@@ -48,6 +79,29 @@ var infixProtos = map[TokenType]map[ObjectType]ObjectType{
 	},
 }
 
+// arithProtos type check, {left, right} => result
+var arithProtos = map[TokenType]map[[2]ObjectType]ObjectType{
+	PLUS: {
+		{INTEGER_OBJ, INTEGER_OBJ}:   INTEGER_OBJ,
+		{FLOAT_OBJ, FLOAT_OBJ}:       FLOAT_OBJ,
+		{INTEGER_OBJ, FLOAT_OBJ}:     FLOAT_OBJ,
+		{FLOAT_OBJ, INTEGER_OBJ}:     FLOAT_OBJ,
+		{STRING_OBJ, STRING_OBJ}:     STRING_OBJ,
+		{TIME_OBJ, DURATION_OBJ}:     TIME_OBJ,
+		{DURATION_OBJ, TIME_OBJ}:     TIME_OBJ,
+		{DURATION_OBJ, DURATION_OBJ}: DURATION_OBJ,
+	},
+	MINUS: {
+		{INTEGER_OBJ, INTEGER_OBJ}:   INTEGER_OBJ,
+		{FLOAT_OBJ, FLOAT_OBJ}:       FLOAT_OBJ,
+		{INTEGER_OBJ, FLOAT_OBJ}:     FLOAT_OBJ,
+		{FLOAT_OBJ, INTEGER_OBJ}:     FLOAT_OBJ,
+		{TIME_OBJ, TIME_OBJ}:         DURATION_OBJ,
+		{TIME_OBJ, DURATION_OBJ}:     TIME_OBJ,
+		{DURATION_OBJ, DURATION_OBJ}: DURATION_OBJ,
+	},
+}
+
 // funcProtos type check
 var funcProtos = map[string][][2][]ObjectType{
 	"len": {
@@ -56,14 +110,103 @@ var funcProtos = map[string][][2][]ObjectType{
 			{INTEGER_OBJ}, // return
 		},
 		{
-			{ARRAY_STRING_OBJ}, // args
-			{INTEGER_OBJ},      // return
+			{ARRAY_OBJ},   // args, 任意数组
+			{INTEGER_OBJ}, // return
 		},
+	},
+	"regexp": {
 		{
-			{ARRAY_INTEGER_OBJ}, // args
-			{INTEGER_OBJ},       // return
+			{STRING_OBJ, STRING_OBJ}, // args
+			{BOOLEAN_OBJ},            // return
 		},
 	},
+	"startsWith": {
+		{{STRING_OBJ, STRING_OBJ}, {BOOLEAN_OBJ}},
+	},
+	"endsWith": {
+		{{STRING_OBJ, STRING_OBJ}, {BOOLEAN_OBJ}},
+	},
+	"now": {
+		{
+			{},         // args
+			{TIME_OBJ}, // return
+		},
+	},
+	"date": {
+		{{TIME_OBJ}, {TIME_OBJ}},
+		{{TIME_OBJ, STRING_OBJ}, {TIME_OBJ}}, // time, tz
+		{{STRING_OBJ}, {TIME_OBJ}},
+		{{STRING_OBJ, STRING_OBJ}, {TIME_OBJ}}, // "2024-01-01", tz
+	},
+	"ip": {
+		{{STRING_OBJ}, {IP_OBJ}},
+	},
+	"cidr_match": {
+		{{IP_OBJ, STRING_OBJ}, {BOOLEAN_OBJ}},
+		{{STRING_OBJ, STRING_OBJ}, {BOOLEAN_OBJ}},
+		{{IP_OBJ, ARRAY_STRING_OBJ}, {BOOLEAN_OBJ}}, // CIDR列表
+		{{STRING_OBJ, ARRAY_STRING_OBJ}, {BOOLEAN_OBJ}},
+	},
+	"is_private": {
+		{{IP_OBJ}, {BOOLEAN_OBJ}},
+		{{STRING_OBJ}, {BOOLEAN_OBJ}},
+	},
+	"ip_version": {
+		{{IP_OBJ}, {INTEGER_OBJ}},
+		{{STRING_OBJ}, {INTEGER_OBJ}},
+	},
+	"semver_compare": {
+		{{STRING_OBJ, STRING_OBJ}, {INTEGER_OBJ}},
+	},
+	"semver_satisfies": {
+		{{STRING_OBJ, STRING_OBJ}, {BOOLEAN_OBJ}}, // version, range
+	},
+	"bucket": {
+		{{STRING_OBJ, STRING_OBJ}, {INTEGER_OBJ}}, // key, salt
+		{{INTEGER_OBJ, STRING_OBJ}, {INTEGER_OBJ}},
+		{{STRING_OBJ, STRING_OBJ, INTEGER_OBJ}, {INTEGER_OBJ}}, // key, salt, buckets
+		{{INTEGER_OBJ, STRING_OBJ, INTEGER_OBJ}, {INTEGER_OBJ}},
+	},
+	"hour": {
+		{{TIME_OBJ}, {INTEGER_OBJ}},
+		{{TIME_OBJ, STRING_OBJ}, {INTEGER_OBJ}}, // time, tz
+	},
+	"weekday": {
+		{{TIME_OBJ}, {INTEGER_OBJ}},
+		{{TIME_OBJ, STRING_OBJ}, {INTEGER_OBJ}}, // time, tz
+	},
+}
+
+// literalCheckers 内置函数常量参数的校验
+var literalCheckers = map[string]func(name string, args []Expression) error{
+	"ip":         checkIPLiterals,
+	"cidr_match": checkIPLiterals,
+	"is_private": checkIPLiterals,
+	"ip_version": checkIPLiterals,
+
+	"semver_compare":   checkSemverLiterals,
+	"semver_satisfies": checkSemverLiterals,
+
+	"bucket": checkBucketLiterals,
+	"regexp": checkRegexpLiterals,
+}
+
+// BuiltinSignatures 返回内置函数的签名，例如 len(STRING) INTEGER
+func BuiltinSignatures() map[string][]string {
+	ret := make(map[string][]string, len(funcProtos))
+	for name, protos := range funcProtos {
+		for _, proto := range protos {
+			args := make([]string, 0, len(proto[0]))
+			for _, arg := range proto[0] {
+				args = append(args, string(arg))
+			}
+			ret[name] = append(ret[name], fmt.Sprintf("%s(%s) %s", name, strings.Join(args, ", "), proto[1][0]))
+		}
+	}
+	for name, sig := range quantifierSignatures() {
+		ret[name] = []string{sig}
+	}
+	return ret
 }
 
 func (p *Parser) CheckType(node Node) ObjectType {
@@ -80,16 +223,26 @@ func (p *Parser) CheckType(node Node) ObjectType {
 	case *Boolean:
 		return BOOLEAN_OBJ
 	case *Identifier:
+		// 声明了类型的标识符按照声明的类型检测
+		if t, ok := p.schema[n.Value]; ok {
+			return t
+		}
 		return IDENT_OBJ
-	case *ArrayString:
-		return ARRAY_STRING_OBJ
-	case *ArrayInteger:
-		return ARRAY_INTEGER_OBJ
+	case *Float:
+		return FLOAT_OBJ
+	case *Array:
+		return p.checkArray(n)
+	case *Time:
+		return TIME_OBJ
+	case *Duration:
+		return DURATION_OBJ
+	case *IP:
+		return IP_OBJ
 	case *PrefixExpresion:
 		{
 			expects, ok := prefixProtos[n.Operator]
 			if !ok {
-				p.errors = append(p.errors, fmt.Sprintf("PrefixExpresion unknow operator(%s)", n.Operator))
+				p.errorAt(p.nodePos(n), "PrefixExpresion unknow operator(%s)", n.Operator)
 				return ERROR_OBJ
 			}
 			right := p.CheckType(n.Right)
@@ -104,62 +257,123 @@ func (p *Parser) CheckType(node Node) ObjectType {
 					return BOOLEAN_OBJ
 				}
 			}
+			if len(p.errors) == 0 {
+				p.errorAt(p.nodePos(n), "PrefixExpresion %s<exp> unknow type(%s)", n.Operator, right)
+			}
 			return ERROR_OBJ
 		}
 
 	case *InfixExpression:
+		if protos, ok := arithProtos[n.Operator]; ok {
+			left := p.CheckType(n.Left)
+			right := p.CheckType(n.Right)
+			if len(p.errors) != 0 {
+				return ERROR_OBJ
+			}
+			// 未声明类型的标识符参与运算时结果的类型未知
+			if left == IDENT_OBJ || right == IDENT_OBJ {
+				return IDENT_OBJ
+			}
+			if t, ok := protos[[2]ObjectType{left, right}]; ok {
+				return t
+			}
+			p.errorAt(p.nodePos(n), "InfixExpression(%s) unsupported types %s %s %s",
+				n.String(), left, n.Operator, right)
+			return ERROR_OBJ
+		}
 		{
 			expects, ok := infixProtos[n.Operator]
 			if !ok {
-				p.errors = append(p.errors, fmt.Sprintf("InfixExpression unknow operator(%s)", n.Operator))
+				p.errorAt(p.nodePos(n), "InfixExpression unknow operator(%s)", n.Operator)
 				return ERROR_OBJ
 			}
 			left := p.CheckType(n.Left)
 			right := p.CheckType(n.Right)
+			// 子表达式已经报告了错误
+			if len(p.errors) != 0 {
+				return ERROR_OBJ
+			}
 
 			// special case
 			if left == IDENT_OBJ || right == IDENT_OBJ {
 				return BOOLEAN_OBJ
 			}
+			// ip只有在字符串数组中时按照CIDR列表检测，例如 ip in [a, b] 按照元素类型检测
+			if n.Operator == IN && (left != IP_OBJ || right != ARRAY_STRING_OBJ) {
+				return p.checkIn(n, left, right)
+			}
 
 			rightExpect, ok := expects[left]
 			if !ok {
-				p.errors = append(p.errors, fmt.Sprintf("InfixExpression(%s) unknow left type(%s)",
-					n.String(), left))
+				p.errorAt(p.nodePos(n.Left), "InfixExpression(%s) unknow left type(%s)",
+					n.String(), left)
 				return ERROR_OBJ
 			}
-			if rightExpect != right {
-				p.errors = append(p.errors, fmt.Sprintf("InfixExpression <exp>%s<exp> right expect %s, got %s",
-					n.Operator, rightExpect, right))
+			if !compatibleTypes(rightExpect, right) {
+				p.errorAt(p.nodePos(n.Right), "InfixExpression <exp>%s<exp> right expect %s, got %s",
+					n.Operator, rightExpect, right)
 				return ERROR_OBJ
 			}
+			if pattern, ok := n.Right.(*String); ok && n.Operator == REG {
+				if _, err := regexp.Compile(pattern.Value); err != nil {
+					p.errorAt(p.nodePos(n.Right), "InfixExpression invalid regexp %q: %s", pattern.Value, err)
+					return ERROR_OBJ
+				}
+			}
+			if left == IP_OBJ && n.Operator == IN {
+				if err := checkCIDRLiterals(n.Right); err != nil {
+					p.errorAt(p.nodePos(n.Right), "InfixExpression %s", err)
+					return ERROR_OBJ
+				}
+			}
 			return BOOLEAN_OBJ
 		}
 	case *CallExpression:
 		{
+			if q, ok := quantifiers[n.Function.String()]; ok {
+				return p.checkQuantifier(q, n)
+			}
 			expects, ok := funcProtos[n.Function.String()]
 			if !ok {
-				p.errors = append(p.errors, fmt.Sprintf("CallExpression unknow function(%s)", n.Function.String()))
-				return ERROR_OBJ
-			}
-			if len(expects[0][0]) != len(n.Arguments) {
-				p.errors = append(p.errors, fmt.Sprintf("CallExpression %s args len error, expect %d, got %d",
-					n.Function.String(), len(expects), len(n.Arguments)))
+				p.errorAt(p.nodePos(n), "CallExpression unknow function(%s)", n.Function.String())
 				return ERROR_OBJ
 			}
+			arities := []string{}
+			arityMatched := false
 			returnType := ERROR_OBJ
 			for _, expectArgs := range expects {
-				if returnType != ERROR_OBJ {
-					break
+				if len(expectArgs[0]) != len(n.Arguments) {
+					arities = append(arities, fmt.Sprint(len(expectArgs[0])))
+					continue
 				}
+				arityMatched = true
+				matched := true
 				for i, expectType := range expectArgs[0] {
 					actual := p.CheckType(n.Arguments[i])
-					if expectType != actual && actual != IDENT_OBJ {
+					if !argumentMatches(expectType, actual) {
+						matched = false
 						break
 					}
-					if i == len(expectArgs[0])-1 {
-						returnType = expectArgs[1][0]
-					}
+				}
+				if matched {
+					returnType = expectArgs[1][0]
+					break
+				}
+			}
+			if len(p.errors) != 0 {
+				return ERROR_OBJ
+			}
+			if returnType == ERROR_OBJ && !arityMatched {
+				p.errorAt(p.nodePos(n), "CallExpression %s args len error, expect %s, got %d",
+					n.Function.String(), strings.Join(arities, " or "), len(n.Arguments))
+			} else if returnType == ERROR_OBJ {
+				p.errorAt(p.nodePos(n), "CallExpression %s args type error, no signature matches %s",
+					n.Function.String(), n.String())
+			} else if check, ok := literalCheckers[n.Function.String()]; ok {
+				// 常量参数在类型检测时校验
+				if err := check(n.Function.String(), n.Arguments); err != nil {
+					p.errorAt(p.nodePos(n), "CallExpression %s", err)
+					return ERROR_OBJ
 				}
 			}
 			return returnType
@@ -167,3 +381,88 @@ func (p *Parser) CheckType(node Node) ObjectType {
 	}
 	return ERROR_OBJ
 }
+
+// checkArray 推断数组的元素类型，整数和浮点数混合时为浮点数，元素类型未知时为ARRAY_OBJ
+// 元素类型不同时报错，除非调用了AllowMixedArrays
+func (p *Parser) checkArray(n *Array) ObjectType {
+	elem := IDENT_OBJ
+	for _, e := range n.Elements {
+		t := p.CheckType(e)
+		if t == ERROR_OBJ {
+			return ERROR_OBJ
+		}
+		u, ok := unifyTypes(elem, t)
+		if !ok {
+			if p.mixedArrays {
+				return ARRAY_OBJ
+			}
+			p.errorAt(p.nodePos(e), "Array elements must be of the same type, got %s and %s", elem, t)
+			return ERROR_OBJ
+		}
+		elem = u
+	}
+	return ArrayOf(elem)
+}
+
+// checkIn 检测 <exp> in <array>，左侧的类型需要和数组的元素类型一致
+func (p *Parser) checkIn(n *InfixExpression, left, right ObjectType) ObjectType {
+	elem, ok := ElementType(right)
+	if !ok {
+		p.errorAt(p.nodePos(n.Right), "InfixExpression <exp>%s<exp> right expect %s, got %s",
+			n.Operator, ArrayOf(left), right)
+		return ERROR_OBJ
+	}
+	if _, ok := unifyTypes(left, elem); !ok {
+		p.errorAt(p.nodePos(n.Right), "InfixExpression <exp>%s<exp> right expect %s, got %s",
+			n.Operator, ArrayOf(left), right)
+		return ERROR_OBJ
+	}
+	return BOOLEAN_OBJ
+}
+
+// unifyTypes 返回两个类型共同的类型，IDENT_OBJ可以和任意类型统一，整数和浮点数统一为浮点数
+func unifyTypes(a, b ObjectType) (ObjectType, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case a == IDENT_OBJ:
+		return b, true
+	case b == IDENT_OBJ:
+		return a, true
+	case isNumericType(a) && isNumericType(b):
+		return FLOAT_OBJ, true
+	}
+	ea, aok := ElementType(a)
+	eb, bok := ElementType(b)
+	if aok && bok {
+		if u, ok := unifyTypes(ea, eb); ok {
+			return ArrayOf(u), true
+		}
+	}
+	return "", false
+}
+
+func isNumericType(t ObjectType) bool {
+	return t == INTEGER_OBJ || t == FLOAT_OBJ
+}
+
+// compatibleTypes 运算符的右侧是否可以是actual类型，整数和浮点数可以比较
+func compatibleTypes(expect, actual ObjectType) bool {
+	_, ok := unifyTypes(expect, actual)
+	return expect == actual || (ok && (isNumericType(expect) || isArrayType(expect)))
+}
+
+// argumentMatches 函数参数的类型检测，ARRAY_OBJ表示任意数组
+func argumentMatches(expect, actual ObjectType) bool {
+	if expect == actual || actual == IDENT_OBJ {
+		return true
+	}
+	if !isArrayType(expect) || !isArrayType(actual) {
+		return false
+	}
+	if expect == ARRAY_OBJ {
+		return true
+	}
+	u, ok := unifyTypes(expect, actual)
+	return ok && u == expect
+}
@@ -0,0 +1,290 @@
+package conditions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver 语义化版本，https://semver.org
+type semver struct {
+	major, minor, patch int64
+	pre                 []string // 先行版本号, 1.0.0-alpha.1 => [alpha 1]
+}
+
+// parseSemver 解析版本号，允许v前缀，忽略+之后的编译信息
+// 返回版本号中数字部分的个数，缺少的minor和patch视为0, 2.1 => 2.1.0
+func parseSemver(s string) (semver, int, error) {
+	var v semver
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		for _, id := range v.pre {
+			if id == "" {
+				return v, 0, fmt.Errorf("invalid version %q, empty pre-release identifier", raw)
+			}
+		}
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, 0, fmt.Errorf("invalid version %q", raw)
+	}
+	nums := [3]*int64{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("invalid version %q", raw)
+		}
+		*nums[i] = n
+	}
+	if v.pre != nil && len(parts) != 3 {
+		return v, 0, fmt.Errorf("invalid version %q, pre-release requires major.minor.patch", raw)
+	}
+	return v, len(parts), nil
+}
+
+// compareSemver 比较两个版本号，返回-1、0或1
+// 先行版本低于对应的正式版本，先行版本号逐段比较：数字按数值比较且低于非数字，
+// 非数字按ASCII比较，前面都相同时段数多的更高
+func compareSemver(a, b semver) int {
+	for _, pair := range [3][2]int64{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if pair[0] != pair[1] {
+			return compareInt64(pair[0], pair[1])
+		}
+	}
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		x, xerr := strconv.ParseUint(a.pre[i], 10, 64)
+		y, yerr := strconv.ParseUint(b.pre[i], 10, 64)
+		switch {
+		case xerr == nil && yerr == nil:
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+		case xerr == nil:
+			return -1
+		case yerr == nil:
+			return 1
+		case a.pre[i] != b.pre[i]:
+			if a.pre[i] < b.pre[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareInt64(int64(len(a.pre)), int64(len(b.pre)))
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// semverConstraint 单个比较条件, >=1.2.0
+type semverConstraint struct {
+	op string
+	v  semver
+}
+
+func (c semverConstraint) match(v semver) bool {
+	cmp := compareSemver(v, c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// parseSemverRange 解析版本范围，|| 分隔的每组条件之间是或的关系，组内空格分隔的条件之间是与的关系
+//
+//	^2.1            >=2.1.0 <3.0.0，^0.2.3 => >=0.2.3 <0.3.0
+//	~1.2.3          >=1.2.3 <1.3.0
+//	>=1.2 <2        比较运算符 = > >= < <=
+//	1.2             >=1.2.0 <1.3.0，完整的版本号表示相等
+func parseSemverRange(s string) ([][]semverConstraint, error) {
+	var ret [][]semverConstraint
+	for _, group := range strings.Split(s, "||") {
+		fields := strings.Fields(group)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version range %q", s)
+		}
+		var constraints []semverConstraint
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			// 允许运算符和版本号之间有空格, >= 1.2
+			if strings.Trim(field, "<>=^~") == "" && i+1 < len(fields) {
+				i++
+				field += fields[i]
+			}
+			cs, err := parseSemverConstraint(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %s", s, err)
+			}
+			constraints = append(constraints, cs...)
+		}
+		ret = append(ret, constraints)
+	}
+	return ret, nil
+}
+
+func parseSemverConstraint(s string) ([]semverConstraint, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			break
+		}
+	}
+	v, parts, err := parseSemver(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	// upper 返回在第n个数字部分上加1后的版本
+	upper := func(n int) semver {
+		switch n {
+		case 0:
+			return semver{major: v.major + 1}
+		case 1:
+			return semver{major: v.major, minor: v.minor + 1}
+		}
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+	}
+	switch op {
+	case "^":
+		// 第一个非0的部分不能变化
+		n := 0
+		switch {
+		case v.major == 0 && v.minor == 0 && parts == 3:
+			n = 2
+		case v.major == 0 && parts >= 2:
+			n = 1
+		}
+		return []semverConstraint{{">=", v}, {"<", upper(n)}}, nil
+	case "~":
+		n := 1
+		if parts == 1 {
+			n = 0
+		}
+		return []semverConstraint{{">=", v}, {"<", upper(n)}}, nil
+	case "", "=":
+		if parts == 3 {
+			return []semverConstraint{{"=", v}}, nil
+		}
+		return []semverConstraint{{">=", v}, {"<", upper(parts - 1)}}, nil
+	}
+	return []semverConstraint{{op, v}}, nil
+}
+
+func satisfiesSemver(v semver, groups [][]semverConstraint) bool {
+	for _, group := range groups {
+		matched := true
+		for _, c := range group {
+			if !c.match(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSemverLiterals 类型检测时校验常量形式的版本号和版本范围
+func checkSemverLiterals(name string, args []Expression) error {
+	for i, arg := range args {
+		s, ok := arg.(*String)
+		if !ok {
+			continue
+		}
+		var err error
+		if name == "semver_satisfies" && i == 1 {
+			_, err = parseSemverRange(s.Value)
+		} else {
+			_, _, err = parseSemver(s.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// semverArgs 解析semver函数的两个字符串参数
+func semverArgs(name string, args []Object) (string, string, *Error) {
+	if len(args) != 2 {
+		return "", "", newError("wrong number of argument. got=%d, want=2", len(args))
+	}
+	a, ok := args[0].(*String)
+	if !ok {
+		return "", "", newError("first argument to `%s` not supported, got %s", name, args[0].ObjectType())
+	}
+	b, ok := args[1].(*String)
+	if !ok {
+		return "", "", newError("second argument to `%s` not supported, got %s", name, args[1].ObjectType())
+	}
+	return a.Value, b.Value, nil
+}
+
+func init() {
+	// semver_compare(a, b) a<b时返回-1，相等返回0，a>b时返回1
+	RegisterBuiltin("semver_compare", func(args ...Object) Object {
+		a, b, e := semverArgs("semver_compare", args)
+		if e != nil {
+			return e
+		}
+		va, _, err := parseSemver(a)
+		if err != nil {
+			return newError("semver_compare: %s", err)
+		}
+		vb, _, err := parseSemver(b)
+		if err != nil {
+			return newError("semver_compare: %s", err)
+		}
+		return &Integer{Value: int64(compareSemver(va, vb))}
+	})
+	RegisterBuiltin("semver_satisfies", func(args ...Object) Object {
+		version, rng, e := semverArgs("semver_satisfies", args)
+		if e != nil {
+			return e
+		}
+		v, _, err := parseSemver(version)
+		if err != nil {
+			return newError("semver_satisfies: %s", err)
+		}
+		groups, err := parseSemverRange(rng)
+		if err != nil {
+			return newError("semver_satisfies: %s", err)
+		}
+		return nativeBoolToBooleanObject(satisfiesSemver(v, groups))
+	})
+}
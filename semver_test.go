@@ -0,0 +1,72 @@
+package conditions
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemverOrdering(t *testing.T) {
+	// https://semver.org/#spec-item-11 中的例子
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.9.0", "1.10.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _, err := parseSemver(ordered[i])
+			assert.Nil(t, err)
+			b, _, _ := parseSemver(ordered[j])
+			assert.Equal(t, compareInt64(int64(i), int64(j)), compareSemver(a, b), "%s %s", ordered[i], ordered[j])
+		}
+	}
+	shuffled := []string{"2.0.0", "1.0.0-beta.11", "1.0.0", "1.0.0-alpha.beta", "1.10.0", "1.0.0-alpha",
+		"1.0.0-rc.1", "1.9.0", "1.0.0-beta", "1.0.0-alpha.1", "1.0.0-beta.2"}
+	sort.Slice(shuffled, func(i, j int) bool {
+		a, _, _ := parseSemver(shuffled[i])
+		b, _, _ := parseSemver(shuffled[j])
+		return compareSemver(a, b) < 0
+	})
+	assert.Equal(t, ordered, shuffled)
+}
+
+func TestSemverBuiltins(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("app_version", &String{Value: "2.10.3"})
+	tests := []struct {
+		input  string
+		expect Object
+	}{
+		{`semver_compare(app_version, "2.9.12")`, &Integer{Value: 1}},
+		{`semver_compare("v1.2.3+build.5", "1.2.3")`, &Integer{Value: 0}},
+		{`semver_compare("1.2.3-rc.1", "1.2.3") < 0`, boolTrue},
+		{`semver_satisfies(app_version, "^2.1")`, boolTrue},
+		{`semver_satisfies(app_version, "~2.9")`, boolFalse},
+		{`semver_satisfies(app_version, ">=1.2 <2")`, boolFalse},
+		{`semver_satisfies(app_version, ">= 1.2 < 2 || >=2.10.0")`, boolTrue},
+		{`semver_satisfies("0.2.9", "^0.2.3")`, boolTrue},
+		{`semver_satisfies("0.3.0", "^0.2.3")`, boolFalse},
+		{`semver_satisfies("0.0.4", "^0.0.3")`, boolFalse},
+		{`semver_satisfies("1.2.7", "1.2")`, boolTrue},
+		{`semver_satisfies("1.3.0", "1.2")`, boolFalse},
+		{`semver_satisfies("3.0.0-beta", "<3")`, boolTrue},
+	}
+	for _, tt := range tests {
+		program, err := Compile(tt.input)
+		if !assert.Nil(t, err, tt.input) {
+			continue
+		}
+		assert.Equal(t, tt.expect, Eval(program, env), tt.input)
+	}
+
+	for _, input := range []string{
+		`semver_compare(app_version, "1.x")`,
+		`semver_satisfies(app_version, "^1.2 ||")`,
+		`semver_satisfies(app_version, ">=1.0.0-")`,
+		`semver_compare(app_version, 1) > 0`,
+	} {
+		_, err := Compile(input)
+		assert.NotNil(t, err, input)
+	}
+}
@@ -0,0 +1,182 @@
+package conditions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLDialect SQL方言，决定标识符引用、占位符和函数的写法
+type SQLDialect string
+
+const (
+	MySQL    SQLDialect = "mysql"
+	Postgres SQLDialect = "postgres"
+	SQLite   SQLDialect = "sqlite"
+)
+
+// ToSQL 将Program翻译为参数化的SQL WHERE子句
+//
+// 标识符翻译为列名，字面量翻译为占位符，返回的args与占位符一一对应；
+// 无法翻译的写法会全部收集到返回的error中
+func ToSQL(program *Program, dialect SQLDialect) (string, []interface{}, error) {
+	switch dialect {
+	case MySQL, Postgres, SQLite:
+	default:
+		return "", nil, fmt.Errorf("unknow sql dialect(%s)", dialect)
+	}
+	t := &sqlTranslator{dialect: dialect}
+	where := t.translate(program.Expression)
+	if len(t.errors) != 0 {
+		return "", nil, fmt.Errorf("cannot translate to sql: %s", strings.Join(t.errors, "; "))
+	}
+	return where, t.args, nil
+}
+
+// 运算符到SQL的映射
+var sqlOperators = map[TokenType]string{
+	EQ:       "=",
+	NOT_EQ:   "<>",
+	LT:       "<",
+	LT_EQUAL: "<=",
+	GT:       ">",
+	GT_EQUAL: ">=",
+	AND:      "AND",
+	OR:       "OR",
+}
+
+type sqlTranslator struct {
+	dialect SQLDialect
+	args    []interface{}
+	errors  []string
+}
+
+func (t *sqlTranslator) errorf(format string, args ...interface{}) string {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+	return ""
+}
+
+func (t *sqlTranslator) translate(node Expression) string {
+	switch n := node.(type) {
+	case *Identifier:
+		return t.column(n.Value)
+	case *Integer:
+		return t.placeholder(n.Value)
+	case *Float:
+		return t.placeholder(n.Value)
+	case *String:
+		return t.placeholder(n.Value)
+	case *Time:
+		return t.placeholder(n.Value)
+	case *Boolean:
+		if t.dialect == SQLite {
+			return map[bool]string{true: "1", false: "0"}[n.Value]
+		}
+		return strings.ToUpper(n.String())
+	case *PrefixExpresion:
+		if n.Operator != BANG {
+			return t.errorf("unsupported prefix operator %s", n.Operator)
+		}
+		return "NOT (" + t.translate(n.Right) + ")"
+	case *InfixExpression:
+		return t.translateInfix(n)
+	case *CallExpression:
+		return t.translateCall(n)
+	}
+	return t.errorf("unsupported expression %s", node.String())
+}
+
+func (t *sqlTranslator) translateInfix(n *InfixExpression) string {
+	switch n.Operator {
+	case IN:
+		return t.translateIn(n)
+	case REG:
+		return t.regexp(n.Left, n.Right)
+	}
+	op, ok := sqlOperators[n.Operator]
+	if !ok {
+		return t.errorf("unsupported operator %s", n.Operator)
+	}
+	left, right := t.translate(n.Left), t.translate(n.Right)
+	if n.Operator == AND || n.Operator == OR {
+		return "(" + left + " " + op + " " + right + ")"
+	}
+	return left + " " + op + " " + right
+}
+
+func (t *sqlTranslator) translateIn(n *InfixExpression) string {
+	left := t.translate(n.Left)
+	arr, ok := n.Right.(*Array)
+	if !ok {
+		return t.errorf("right side of in must be an array literal, got %s", n.Right.String())
+	}
+	if _, ok := cidrList(arr); ok {
+		return t.errorf("%s matches CIDRs, which sql does not support", n.String())
+	}
+	if len(arr.Elements) == 0 {
+		// IN () 不是合法的SQL
+		return "1 = 0"
+	}
+	var items []string
+	for _, elem := range arr.Elements {
+		items = append(items, t.translate(elem))
+	}
+	return left + " IN (" + strings.Join(items, ", ") + ")"
+}
+
+func (t *sqlTranslator) translateCall(n *CallExpression) string {
+	switch name := n.Function.String(); name {
+	case "len":
+		if len(n.Arguments) != 1 {
+			return t.errorf("wrong number of argument to len. got=%d, want=1", len(n.Arguments))
+		}
+		if _, ok := n.Arguments[0].(*Identifier); !ok {
+			return t.errorf("argument to len must be a column, got %s", n.Arguments[0].String())
+		}
+		return t.length(t.translate(n.Arguments[0]))
+	case "regexp":
+		if len(n.Arguments) != 2 {
+			return t.errorf("wrong number of argument to regexp. got=%d, want=2", len(n.Arguments))
+		}
+		return t.regexp(n.Arguments[0], n.Arguments[1])
+	default:
+		return t.errorf("unsupported function %s", name)
+	}
+}
+
+func (t *sqlTranslator) regexp(subject, pattern Expression) string {
+	s, p := t.translate(subject), t.translate(pattern)
+	if t.dialect == Postgres {
+		return s + " ~ " + p
+	}
+	// SQLite需要加载提供REGEXP函数的扩展
+	return s + " REGEXP " + p
+}
+
+func (t *sqlTranslator) length(s string) string {
+	if t.dialect == SQLite {
+		return "LENGTH(" + s + ")"
+	}
+	return "CHAR_LENGTH(" + s + ")"
+}
+
+// column 引用列名，点分隔的每一段分别引用，Order.City => "Order"."City"，
+// 列名中的引号重复一次转义，JSON得到的AST中标识符可以包含任意字符
+func (t *sqlTranslator) column(name string) string {
+	quote := "\""
+	if t.dialect == MySQL {
+		quote = "`"
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.Replace(part, quote, quote+quote, -1) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+func (t *sqlTranslator) placeholder(v interface{}) string {
+	t.args = append(t.args, v)
+	if t.dialect == Postgres {
+		return fmt.Sprintf("$%d", len(t.args))
+	}
+	return "?"
+}
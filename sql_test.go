@@ -0,0 +1,77 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToSQL(t *testing.T) {
+	input := `(len(name) > 1 && age >= 18) || !(country in ["CN", "SG"]) || vip == true`
+	program := NewParser(NewLexer(input)).ParseProgram()
+
+	tests := []struct {
+		dialect  SQLDialect
+		expected string
+	}{
+		{MySQL, "(((CHAR_LENGTH(`name`) > ? AND `age` >= ?) OR NOT (`country` IN (?, ?))) OR `vip` = TRUE)"},
+		{Postgres, `(((CHAR_LENGTH("name") > $1 AND "age" >= $2) OR NOT ("country" IN ($3, $4))) OR "vip" = TRUE)`},
+		{SQLite, `(((LENGTH("name") > ? AND "age" >= ?) OR NOT ("country" IN (?, ?))) OR "vip" = 1)`},
+	}
+	for _, tt := range tests {
+		where, args, err := ToSQL(program, tt.dialect)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, where)
+		assert.Equal(t, []interface{}{int64(1), int64(18), "CN", "SG"}, args)
+	}
+
+	program = NewParser(NewLexer(`zero(name) && len("abc") > 1`)).ParseProgram()
+	_, _, err := ToSQL(program, MySQL)
+	assert.EqualError(t, err, "cannot translate to sql: unsupported function zero; argument to len must be a column, got \"abc\"")
+}
+
+// 翻译结果按照SQLite的语义人工核对过，字符串按照BINARY排序规则逐字节比较，和Eval一致
+func TestToSQLCases(t *testing.T) {
+	tests := []struct {
+		input string
+		where string
+		args  []interface{}
+	}{
+		{`age >= 18 && country in ["CN", "SG"]`, `("age" >= ? AND "country" IN (?, ?))`, []interface{}{int64(18), "CN", "SG"}},
+		{`!(country in ["CN"]) || age == 18`, `(NOT ("country" IN (?)) OR "age" = ?)`, []interface{}{"CN", int64(18)}},
+		{`len(name) > 3 || vip == true`, `(LENGTH("name") > ? OR "vip" = 1)`, []interface{}{int64(3)}},
+		{`name > "abcd"`, `"name" > ?`, []interface{}{"abcd"}},
+		{`"abc" <= name && age != 20`, `(? <= "name" AND "age" <> ?)`, []interface{}{"abc", int64(20)}},
+		{`name ~= "^a" || regexp(name, "d$")`, `("name" REGEXP ? OR "name" REGEXP ?)`, []interface{}{"^a", "d$"}},
+		{`age in [] || !vip`, `(1 = 0 OR NOT ("vip"))`, nil},
+		{`vip && score >= 1.5`, `("vip" AND "score" >= ?)`, []interface{}{1.5}},
+		{`score < 2 && name == "zed"`, `("score" < ? AND "name" = ?)`, []interface{}{int64(2), "zed"}},
+		{`len(name) < age`, `LENGTH("name") < "age"`, nil},
+		{`name < country`, `"name" < "country"`, nil},
+	}
+	for _, tt := range tests {
+		where, args, err := ToSQL(mustCompile(t, tt.input), SQLite)
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.where, where, tt.input)
+			assert.Equal(t, tt.args, args, tt.input)
+		}
+	}
+}
+
+// 点分隔的每一段分别引用，列名中的引号重复一次
+func TestToSQLColumnQuoting(t *testing.T) {
+	program := &Program{Expression: &InfixExpression{
+		Left:     &InfixExpression{Left: &Identifier{Value: "Order.City"}, Operator: EQ, Right: &String{Value: "x"}},
+		Operator: AND,
+		Right:    &InfixExpression{Left: &Identifier{Value: "a`b\"c"}, Operator: GT, Right: &Integer{Value: 1}},
+	}}
+	for dialect, expected := range map[SQLDialect]string{
+		MySQL:    "(`Order`.`City` = ? AND `a``b\"c` > ?)",
+		Postgres: `("Order"."City" = $1 AND "a` + "`" + `b""c" > $2)`,
+		SQLite:   `("Order"."City" = ? AND "a` + "`" + `b""c" > ?)`,
+	} {
+		where, _, err := ToSQL(program, dialect)
+		assert.Nil(t, err, dialect)
+		assert.Equal(t, expected, where, dialect)
+	}
+}
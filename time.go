@@ -0,0 +1,287 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 时间间隔支持的单位，按从大到小的顺序格式化
+var durationUnits = []struct {
+	unit  string
+	value time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"ms", time.Millisecond},
+	{"us", time.Microsecond},
+	{"ns", time.Nanosecond},
+}
+
+// errDurationRange 时间间隔超出time.Duration的范围
+var errDurationRange = errors.New("out of range")
+
+// parseDuration 解析带有单位的时间间隔, 7d 15m 1h30m
+// 与time.ParseDuration不同，支持d(天)和w(周)
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	// 负数字面量和JSON中的负数带有负号
+	rest, negative := s, false
+	if strings.HasPrefix(rest, "-") && len(rest) > 1 {
+		rest, negative = rest[1:], true
+	}
+	// 按照无符号数累加并检查溢出，负数可以取到最小的time.Duration
+	limit := uint64(math.MaxInt64)
+	if negative {
+		limit++
+	}
+	var total uint64
+	for rest != "" {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+		j := i
+		for j < len(rest) && isLetter(rest[j]) {
+			j++
+		}
+		n, err := strconv.ParseUint(rest[:i], 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("duration %q %w", s, errDurationRange)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		unit := time.Duration(0)
+		for _, u := range durationUnits {
+			if u.unit == rest[i:j] {
+				unit = u.value
+			}
+		}
+		if unit == 0 {
+			return 0, fmt.Errorf("invalid duration %q, unknown unit %q", s, rest[i:j])
+		}
+		if n > (limit-total)/uint64(unit) {
+			return 0, fmt.Errorf("duration %q %w", s, errDurationRange)
+		}
+		total += n * uint64(unit)
+		rest = rest[j:]
+	}
+	if negative {
+		return time.Duration(-total), nil
+	}
+	return time.Duration(total), nil
+}
+
+// formatDuration 将时间间隔格式化为可以被parseDuration解析的形式, 7d 1h30m 1ms500us
+// parseDuration(formatDuration(d)) == d
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+	var out strings.Builder
+	// 使用无符号数，避免最小的负数取反后溢出
+	v := uint64(d)
+	if d < 0 {
+		out.WriteString("-")
+		v = -v
+	}
+	for _, u := range durationUnits[1:] { // 不使用周，7d比1w更直观
+		if unit := uint64(u.value); v >= unit {
+			fmt.Fprintf(&out, "%d%s", v/unit, u.unit)
+			v %= unit
+		}
+	}
+	return out.String()
+}
+
+// parseTime 解析时间字面量，支持RFC3339和2006-01-02格式，没有时区时使用loc
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want RFC3339 or 2006-01-02", s)
+}
+
+// loadLocation 加载时区，支持IANA时区名称(Asia/Shanghai)和固定偏移(+08:00)
+func loadLocation(tz string) (*time.Location, error) {
+	if t, err := time.Parse("-07:00", tz); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	return loc, nil
+}
+
+var clock atomic.Value // func() time.Time，在init中设置为time.Now
+
+// SetClock 设置now()使用的时钟，返回恢复原时钟的函数，用于在测试中固定当前时间
+//
+//	defer conditions.SetClock(func() time.Time { return fixed })()
+func SetClock(now func() time.Time) (restore func()) {
+	prev := clock.Load().(func() time.Time)
+	clock.Store(now)
+	return func() { clock.Store(prev) }
+}
+
+// volatileBuiltins 每次调用结果可能不同的内置函数，部分求值和优化时不会被折叠
+var volatileBuiltins = map[string]bool{
+	"now": true,
+}
+
+// evalTemporalInfixExpression 执行时间和时间间隔的比较、加法和减法
+func evalTemporalInfixExpression(operator TokenType, left, right Object) Object {
+	switch l := left.(type) {
+	case *Time:
+		switch r := right.(type) {
+		case *Time:
+			switch operator {
+			case MINUS:
+				return &Duration{Value: l.Value.Sub(r.Value)}
+			case LT:
+				return nativeBoolToBooleanObject(l.Value.Before(r.Value))
+			case GT:
+				return nativeBoolToBooleanObject(l.Value.After(r.Value))
+			case LT_EQUAL:
+				return nativeBoolToBooleanObject(!l.Value.After(r.Value))
+			case GT_EQUAL:
+				return nativeBoolToBooleanObject(!l.Value.Before(r.Value))
+			case EQ:
+				return nativeBoolToBooleanObject(l.Value.Equal(r.Value))
+			case NOT_EQ:
+				return nativeBoolToBooleanObject(!l.Value.Equal(r.Value))
+			}
+		case *Duration:
+			switch operator {
+			case PLUS:
+				return &Time{Value: l.Value.Add(r.Value)}
+			case MINUS:
+				return &Time{Value: l.Value.Add(-r.Value)}
+			}
+		}
+	case *Duration:
+		switch r := right.(type) {
+		case *Duration:
+			switch operator {
+			case PLUS:
+				return &Duration{Value: l.Value + r.Value}
+			case MINUS:
+				return &Duration{Value: l.Value - r.Value}
+			default:
+				// 时间间隔按照整数比较
+				result := evalIntegerInfixExpression(operator, &Integer{Value: int64(l.Value)}, &Integer{Value: int64(r.Value)})
+				if _, ok := result.(*Boolean); ok {
+					return result
+				}
+			}
+		case *Time:
+			if operator == PLUS {
+				return &Time{Value: r.Value.Add(l.Value)}
+			}
+		}
+	}
+	return newError("unknow operator: %s %s %s", left.ObjectType(), operator, right.ObjectType())
+}
+
+func isTemporal(obj Object) bool {
+	return obj.ObjectType() == TIME_OBJ || obj.ObjectType() == DURATION_OBJ
+}
+
+// timeArgs 解析时间函数的参数 (time[, tz])，返回转换到时区后的时间
+func timeArgs(name string, args []Object) (time.Time, *Error) {
+	if len(args) != 1 && len(args) != 2 {
+		return time.Time{}, newError("wrong number of argument. got=%d, want=1 or 2", len(args))
+	}
+	t, ok := args[0].(*Time)
+	if !ok {
+		return time.Time{}, newError("first argument to `%s` not supported, got %s", name, args[0].ObjectType())
+	}
+	if len(args) == 1 {
+		return t.Value.UTC(), nil
+	}
+	loc, err := tzArg(name, args[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Value.In(loc), nil
+}
+
+func tzArg(name string, arg Object) (*time.Location, *Error) {
+	tz, ok := arg.(*String)
+	if !ok {
+		return nil, newError("second argument to `%s` not supported, got %s", name, arg.ObjectType())
+	}
+	loc, err := loadLocation(tz.Value)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+	return loc, nil
+}
+
+func init() {
+	clock.Store(time.Now)
+	RegisterBuiltin("now", func(args ...Object) Object {
+		if len(args) != 0 {
+			return newError("wrong number of argument. got=%d, want=0", len(args))
+		}
+		return &Time{Value: clock.Load().(func() time.Time)()}
+	})
+	// date(time[, tz]) 返回时区中当天的零点，date(string[, tz]) 解析时间字符串
+	RegisterBuiltin("date", func(args ...Object) Object {
+		if len(args) > 0 {
+			if s, ok := args[0].(*String); ok {
+				loc := time.UTC
+				if len(args) == 2 {
+					var err *Error
+					if loc, err = tzArg("date", args[1]); err != nil {
+						return err
+					}
+				} else if len(args) != 1 {
+					return newError("wrong number of argument. got=%d, want=1 or 2", len(args))
+				}
+				t, err := parseTime(s.Value, loc)
+				if err != nil {
+					return newError("%s", err)
+				}
+				return &Time{Value: t}
+			}
+		}
+		t, err := timeArgs("date", args)
+		if err != nil {
+			return err
+		}
+		return &Time{Value: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())}
+	})
+	RegisterBuiltin("hour", func(args ...Object) Object {
+		t, err := timeArgs("hour", args)
+		if err != nil {
+			return err
+		}
+		return &Integer{Value: int64(t.Hour())}
+	})
+	// weekday 0表示星期日
+	RegisterBuiltin("weekday", func(args ...Object) Object {
+		t, err := timeArgs("weekday", args)
+		if err != nil {
+			return err
+		}
+		return &Integer{Value: int64(t.Weekday())}
+	})
+}
@@ -0,0 +1,119 @@
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTime(t *testing.T) {
+	// 2024-03-10 是星期日
+	fixed := time.Date(2024, 3, 10, 20, 30, 0, 0, time.UTC)
+	defer SetClock(func() time.Time { return fixed })()
+
+	env := NewEnvironment()
+	env.Set("placed_at", &Time{Value: fixed.Add(-3 * 24 * time.Hour)})
+	env.Set("registered", &Time{Value: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)})
+
+	tests := []struct {
+		input  string
+		expect Object
+	}{
+		{`now() - placed_at < 7d`, boolTrue},
+		{`now() - placed_at <= 2d`, boolFalse},
+		{`registered < @2024-01-01T00:00:00Z`, boolTrue},
+		{`registered == @2023-06-01`, boolTrue},
+		{`placed_at + 3d == now()`, boolTrue},
+		{`1h30m == 90m`, boolTrue},
+		{`now() - @2024-03-10T12:30:00+08:00`, &Duration{Value: 16 * time.Hour}},
+		{`hour(now())`, &Integer{Value: 20}},
+		{`hour(now(), "Asia/Shanghai")`, &Integer{Value: 4}},
+		{`weekday(now())`, &Integer{Value: 0}},
+		{`weekday(now(), "+08:00")`, &Integer{Value: 1}},
+		{`date(now()) == @2024-03-10`, boolTrue},
+		{`date(now(), "+08:00") == @2024-03-11T00:00:00+08:00`, boolTrue},
+		{`date("2024-03-10", "+08:00") == @2024-03-09T16:00:00Z`, boolTrue},
+		{`1 + 2 - 4`, &Integer{Value: -1}},
+	}
+	for _, tt := range tests {
+		program, err := Compile(tt.input)
+		if !assert.Nil(t, err, tt.input) {
+			continue
+		}
+		assert.Equal(t, tt.expect, Eval(program, env), tt.input)
+	}
+}
+
+func TestTimeTypeCheck(t *testing.T) {
+	schema := Schema{"placed_at": TIME_OBJ, "age": INTEGER_OBJ}
+	for input, ok := range map[string]bool{
+		`now() - placed_at > 7d`:        true,
+		`placed_at > @2024-01-01`:       true,
+		`hour(placed_at, "UTC") >= 9`:   true,
+		`placed_at > 7d`:                false,
+		`now() - placed_at > 7`:         false,
+		`placed_at + age > now()`:       false,
+		`hour(age) > 1`:                 false,
+		`weekday() == 1`:                false,
+		`date("2024-01-01") < now()`:    true,
+		`@2024-13-01T00:00:00Z < now()`: false,
+	} {
+		p := NewParser(NewLexer(input))
+		p.SetSchema(schema)
+		p.ParseProgram()
+		assert.Equal(t, ok, len(p.Errors()) == 0, "%s: %v", input, p.Errors())
+	}
+}
+
+func TestDurationLiteral(t *testing.T) {
+	for lit, d := range map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		"15m":   15 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"2w":    14 * 24 * time.Hour,
+		"500ms": 500 * time.Millisecond,
+	} {
+		parsed, err := parseDuration(lit)
+		assert.Nil(t, err)
+		assert.Equal(t, d, parsed, lit)
+		again, _ := parseDuration(formatDuration(d))
+		assert.Equal(t, d, again, lit)
+	}
+	_, err := parseDuration("7y")
+	assert.NotNil(t, err)
+
+	// 超出time.Duration范围的字面量在解析时报错，不会溢出为负数
+	for _, lit := range []string{"200000d", "106752d", "106751d23h47m16s854ms775us808ns", "99999999999999999999s", "9223372036854775807ns1ns"} {
+		_, err := parseDuration(lit)
+		assert.EqualError(t, err, fmt.Sprintf("duration %q out of range", lit))
+	}
+	for lit, d := range map[string]time.Duration{
+		"106751d23h47m16s854ms775us807ns":  math.MaxInt64,
+		"-106751d23h47m16s854ms775us808ns": math.MinInt64,
+	} {
+		parsed, err := parseDuration(lit)
+		assert.Nil(t, err, lit)
+		assert.Equal(t, d, parsed, lit)
+		assert.Equal(t, lit, formatDuration(d))
+	}
+	p := NewParser(NewLexer(`200000d > 1d`))
+	p.ParseProgram()
+	assert.Equal(t, []string{`could not parse "200000d" as duration: duration "200000d" out of range`}, p.Errors())
+	p = NewParser(NewLexer(`d > -106751d23h47m16s854ms775us808ns`))
+	parsed := p.ParseProgram()
+	assert.Empty(t, p.Errors())
+	assert.Equal(t, &Duration{Value: math.MinInt64}, parsed.Expression.(*InfixExpression).Right)
+	p = NewParser(NewLexer(`d > 106751d23h47m16s854ms775us808ns`))
+	p.ParseProgram()
+	assert.NotEmpty(t, p.Errors())
+
+	// 部分求值不会折叠now()
+	program, err := Compile(`now() - @2024-01-01 > 7d`)
+	assert.Nil(t, err)
+	partial, err := PartialEval(program, NewEnvironment())
+	assert.Nil(t, err)
+	assert.Equal(t, "now() - @2024-01-01T00:00:00Z > 7d", Format(partial))
+}
@@ -5,6 +5,7 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     int // 词法单元在输入中的字节偏移
 }
 
 const (
@@ -13,13 +14,17 @@ const (
 	EOF     TokenType = "EOF"
 
 	// identifier + literal
-	IDENT  TokenType = "IDENT"
-	INT    TokenType = "INT"
-	FLOAT  TokenType = "FLOAT"
-	STRING TokenType = "STRING"
+	IDENT    TokenType = "IDENT"
+	INT      TokenType = "INT"
+	FLOAT    TokenType = "FLOAT"
+	STRING   TokenType = "STRING"
+	TIME     TokenType = "TIME"     // @2024-01-01T00:00:00Z
+	DURATION TokenType = "DURATION" // 7d 15m
 
 	// operator
 	BANG     TokenType = "!"
+	PLUS     TokenType = "+"
+	MINUS    TokenType = "-"
 	LT       TokenType = "<"
 	LT_EQUAL TokenType = "<="
 	GT       TokenType = ">"
@@ -77,10 +82,11 @@ var reserved = map[string]struct{}{
 	"switch":      {},
 	"type":        {},
 	"var":         {},
-	// special string
-	"F": {},
 }
 
+// SelfIdent 结构体校验时代表当前字段的标识符, cond:"len(F) > 0"
+const SelfIdent = "F"
+
 // LookupIdent
 func LookupIdent(ident string) TokenType {
 	if t, ok := keywords[ident]; ok {
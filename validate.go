@@ -0,0 +1,332 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 结构体校验使用的tag
+const (
+	CondTag    = "cond" // 字段的校验规则, cond:"len(F) > 0 && F ~= \"^[a-z]+$\""
+	CondMsgTag = "msg"  // 校验失败时的错误信息，为空时使用默认的信息
+)
+
+// FieldError 一个字段规则或者结构体规则的校验错误
+type FieldError struct {
+	Field   string   // 字段名，结构体规则为空
+	Fields  []string // 规则涉及的字段，字段规则只包括字段本身
+	Rule    string   // 校验规则
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", strings.Join(e.Fields, ", "), e.Message)
+}
+
+// ValidationErrors 结构体校验发现的所有错误，按照字段的顺序排列
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Fields 返回校验失败涉及的字段名，已去重
+func (errs ValidationErrors) Fields() []string {
+	fields := make([]string, 0, len(errs))
+	seen := map[string]bool{}
+	for _, e := range errs {
+		for _, f := range e.Fields {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// StructRule 结构体级别的校验规则，可以引用所有的字段，
+// 包括嵌套结构体的字段(Address.City)以及结构体切片展开后的数组(Items.Qty)
+type StructRule struct {
+	Rule    string
+	Message string // 校验失败时的错误信息，为空时使用默认的信息
+}
+
+// structRule 编译后的结构体规则
+type structRule struct {
+	StructRule
+	fields  []string
+	program *Program
+}
+
+var (
+	structRulesMu sync.RWMutex
+	structRules   = map[reflect.Type][]*structRule{}
+)
+
+// RegisterStructRules 为sample的结构体类型注册结构体级别的规则，Validate在字段规则之后检查
+// 规则在注册时进行语法解析和类型检测，有错误时不会注册任何规则
+//
+//	RegisterStructRules(Booking{},
+//		StructRule{Rule: "StartDate < EndDate", Message: "start date must be before end date"},
+//		StructRule{Rule: "Password == Confirm"},
+//	)
+func RegisterStructRules(sample interface{}, rules ...StructRule) error {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot register rules for %v, want struct", t)
+	}
+	schema := structSchema(t)
+	compiled := make([]*structRule, 0, len(rules))
+	for _, rule := range rules {
+		program, err := compileRule(rule.Rule, schema)
+		if err != nil {
+			return fmt.Errorf("%s: invalid rule %q: %s", t.Name(), rule.Rule, err)
+		}
+		compiled = append(compiled, &structRule{StructRule: rule, fields: Variables(program), program: program})
+	}
+	structRulesMu.Lock()
+	structRules[t] = append(structRules[t], compiled...)
+	structRulesMu.Unlock()
+	return nil
+}
+
+// registeredRules 返回结构体类型注册的规则
+func registeredRules(t reflect.Type) []*structRule {
+	structRulesMu.RLock()
+	defer structRulesMu.RUnlock()
+	return structRules[t]
+}
+
+// fieldRule 编译后的字段规则
+type fieldRule struct {
+	index   int
+	name    string
+	rule    string
+	message string
+	fields  []string // 规则引用的字段，包括F
+	program *Program
+}
+
+// 缓存结构体类型编译后的规则
+var fieldRulesCache sync.Map // map[reflect.Type][]*fieldRule
+
+// Validate 按照结构体字段的cond tag校验v，v必须是结构体或者结构体指针
+//
+// 规则中F代表当前字段，其它导出字段可以通过字段名访问；规则的语法错误和类型错误
+// 作为普通的error返回，校验失败时返回ValidationErrors。字段规则之后检查RegisterStructRules
+// 注册的结构体规则。nil指针字段表示没有值，引用了nil指针字段的规则不检查
+//
+//	type User struct {
+//		Name string `cond:"len(F) > 0 && F ~= \"^[a-z]+$\"" msg:"name must be lowercase letters"`
+//		Age  int    `cond:"F >= 18"`
+//	}
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return fmt.Errorf("cannot validate nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate %s, want struct", rv.Type())
+	}
+	rules, err := fieldRules(rv.Type())
+	if err != nil {
+		return err
+	}
+	structRules := registeredRules(rv.Type())
+	if len(rules) == 0 && len(structRules) == 0 {
+		return nil
+	}
+	env := NewEnvironment()
+	if err := bindValue(env, rv); err != nil {
+		return err
+	}
+	var errs ValidationErrors
+	for _, rule := range rules {
+		if msg, ok := rule.check(env, rv.Field(rule.index)); !ok {
+			errs = append(errs, &FieldError{Field: rule.name, Fields: []string{rule.name}, Rule: rule.rule, Message: msg})
+		}
+	}
+	// 结构体规则中没有F，上面绑定的F不会影响结果
+	for _, rule := range structRules {
+		if !bound(env, rule.fields) {
+			continue
+		}
+		if msg, ok := evalRule(rule.program, env, rule.Rule); !ok {
+			if rule.Message != "" {
+				msg = rule.Message
+			}
+			errs = append(errs, &FieldError{Fields: rule.fields, Rule: rule.Rule, Message: msg})
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// check 将F绑定为字段的值并对规则求值，失败时返回错误信息
+// 字段或者规则引用的字段是nil指针时不检查
+func (r *fieldRule) check(env *Environment, field reflect.Value) (string, bool) {
+	if field.Kind() == reflect.Ptr && field.IsNil() {
+		return "", true
+	}
+	self, err := valueToObject(field)
+	if err != nil {
+		return r.fail(err.Error()), false
+	}
+	env.Set(SelfIdent, self)
+	if !bound(env, r.fields) {
+		return "", true
+	}
+	msg, ok := evalRule(r.program, env, r.rule)
+	if !ok {
+		return r.fail(msg), false
+	}
+	return "", true
+}
+
+// bound 判断规则引用的字段是否都已经绑定，nil指针字段不会绑定
+func bound(env *Environment, fields []string) bool {
+	for _, name := range fields {
+		if _, ok := env.Get(name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// evalRule 对规则求值，失败时返回默认的错误信息
+func evalRule(program *Program, env *Environment, rule string) (string, bool) {
+	switch obj := Eval(program, env).(type) {
+	case *Boolean:
+		if obj.Value {
+			return "", true
+		}
+		return fmt.Sprintf("validation failed: %s", rule), false
+	case *Error:
+		return obj.Message, false
+	}
+	return fmt.Sprintf("rule %s is not a boolean expression", rule), false
+}
+
+// fail 有自定义信息时使用自定义信息
+func (r *fieldRule) fail(msg string) string {
+	if r.message != "" {
+		return r.message
+	}
+	return msg
+}
+
+// fieldRules 解析并编译结构体类型中所有的cond tag
+func fieldRules(t reflect.Type) ([]*fieldRule, error) {
+	if rules, ok := fieldRulesCache.Load(t); ok {
+		return rules.([]*fieldRule), nil
+	}
+	schema := structSchema(t)
+	var rules []*fieldRule
+	for _, i := range structFields(t) {
+		f := t.Field(i)
+		rule, ok := f.Tag.Lookup(CondTag)
+		if !ok {
+			continue
+		}
+		fieldSchema := make(Schema, len(schema)+1)
+		for name, typ := range schema {
+			fieldSchema[name] = typ
+		}
+		if typ, ok := schema[f.Name]; ok {
+			fieldSchema[SelfIdent] = typ
+		}
+		program, err := compileRule(rule, fieldSchema)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: invalid %s tag %q: %s", t.Name(), f.Name, CondTag, rule, err)
+		}
+		rules = append(rules, &fieldRule{
+			index:   i,
+			name:    f.Name,
+			rule:    rule,
+			message: f.Tag.Get(CondMsgTag),
+			fields:  Variables(program),
+			program: program,
+		})
+	}
+	fieldRulesCache.Store(t, rules)
+	return rules, nil
+}
+
+// compileRule 解析规则并按照结构体的schema检测类型，规则只能引用schema中的字段
+func compileRule(rule string, schema Schema) (*Program, error) {
+	p := NewParser(NewLexer(rule))
+	p.SetSchema(schema)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 || program.Expression == nil {
+		return nil, errors.New(strings.Join(p.Errors(), "; "))
+	}
+	for _, name := range Variables(program) {
+		if _, ok := schema[name]; !ok {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+	}
+	if typ := p.CheckType(program); typ != BOOLEAN_OBJ && typ != IDENT_OBJ {
+		return nil, fmt.Errorf("rule is %s, want a boolean expression", typ)
+	}
+	return program, nil
+}
+
+// structSchema 根据结构体导出字段的类型生成schema，不支持的类型不会出现在schema中
+func structSchema(t reflect.Type) Schema {
+	schema := Schema{}
+	flattenSchema(t, "", nil, schema)
+	return schema
+}
+
+// typeToObjectType 返回go类型绑定后的Object类型，与valueToObject的转换规则一致
+func typeToObjectType(t reflect.Type) (ObjectType, bool) {
+	switch t {
+	case timeType:
+		return TIME_OBJ, true
+	case durationType:
+		return DURATION_OBJ, true
+	case ipType:
+		return IP_OBJ, true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return INTEGER_OBJ, true
+	case reflect.Float32, reflect.Float64:
+		return FLOAT_OBJ, true
+	case reflect.String:
+		return STRING_OBJ, true
+	case reflect.Bool:
+		return BOOLEAN_OBJ, true
+	case reflect.Ptr:
+		return typeToObjectType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		// []byte不作为整数数组
+		if t.Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		if t.Elem().Kind() == reflect.Interface {
+			return ARRAY_OBJ, true
+		}
+		if elem, ok := typeToObjectType(t.Elem()); ok && elem != IP_OBJ {
+			return ArrayOf(elem), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,238 @@
+package conditions
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type signup struct {
+	Name     string        `cond:"len(F) > 0 && F ~= \"^[a-z]+$\"" msg:"name must be lowercase letters"`
+	Age      int           `cond:"F >= 18"`
+	Email    string        `cond:"regexp(F, \"@\") && F != Name"`
+	Tags     []string      `cond:"len(F) <= 3"`
+	Timeout  time.Duration `cond:"F <= 1h"`
+	Referrer *string       `cond:"F != Name" msg:"cannot refer yourself"`
+	Note     string
+}
+
+func TestValidate(t *testing.T) {
+	ref := "bob"
+	ok := signup{Name: "alice", Age: 20, Email: "a@x.com", Tags: []string{"a"}, Timeout: time.Minute, Referrer: &ref}
+	assert.Nil(t, Validate(ok))
+	assert.Nil(t, Validate(&ok))
+
+	self := "Alice"
+	bad := signup{Name: "Alice", Age: 17, Email: "Alice", Tags: []string{"a", "b", "c", "d"},
+		Timeout: 2 * time.Hour, Referrer: &self}
+	err := Validate(bad)
+	errs, isValidation := err.(ValidationErrors)
+	if !assert.True(t, isValidation, "%v", err) {
+		return
+	}
+	assert.Equal(t, []string{"Name", "Age", "Email", "Tags", "Timeout", "Referrer"}, errs.Fields())
+	assert.Equal(t, "name must be lowercase letters", errs[0].Message)
+	assert.Equal(t, `validation failed: F >= 18`, errs[1].Message)
+	assert.Equal(t, "Referrer: cannot refer yourself", errs[5].Error())
+
+	// nil指针字段没有值，规则不检查
+	ok.Referrer = nil
+	assert.Nil(t, Validate(ok))
+
+	type nickname struct {
+		Nick *string
+		Name string `cond:"F != Nick" msg:"name must differ from nick"`
+	}
+	assert.Nil(t, Validate(nickname{Name: "alice"}))
+	assert.EqualError(t, Validate(nickname{Name: "alice", Nick: &ok.Name}), "Name: name must differ from nick")
+
+	assert.EqualError(t, Validate(1), "cannot validate int, want struct")
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	type typeMismatch struct {
+		Age int `cond:"F ~= \"^1\""`
+	}
+	assert.EqualError(t, Validate(typeMismatch{}),
+		`typeMismatch.Age: invalid cond tag "F ~= \"^1\"": InfixExpression((F ~= "^1")) unknow left type(INTEGER)`)
+
+	type badRegexp struct {
+		Name string `cond:"F ~= \"[a-\""`
+	}
+	assert.Contains(t, Validate(badRegexp{}).Error(), "invalid regexp")
+
+	type notBoolean struct {
+		Name string `cond:"len(F)"`
+	}
+	assert.EqualError(t, Validate(notBoolean{}),
+		`notBoolean.Name: invalid cond tag "len(F)": rule is INTEGER, want a boolean expression`)
+}
+
+type bookingItem struct {
+	SKU string
+	Qty int
+}
+
+type bookingAddress struct {
+	City string
+}
+
+type bookingBase struct {
+	ID int
+}
+
+type booking struct {
+	bookingBase
+	Start    time.Time
+	End      time.Time
+	Password string
+	Confirm  string
+	Address  *bookingAddress
+	Items    []bookingItem
+}
+
+// 规则是全局注册的，只能注册一次
+var errBookingRules = RegisterStructRules(&booking{},
+	StructRule{Rule: "Start < End", Message: "start must be before end"},
+	StructRule{Rule: "Password == Confirm"},
+	StructRule{Rule: "Address.City in [\"Beijing\", \"Shanghai\"] && 0 in Items.Qty == false && ID > 0"},
+)
+
+func TestValidateStructRules(t *testing.T) {
+	assert.Nil(t, errBookingRules)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ok := booking{
+		bookingBase: bookingBase{ID: 1},
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Password:    "secret",
+		Confirm:     "secret",
+		Address:     &bookingAddress{City: "Beijing"},
+		Items:       []bookingItem{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 2}},
+	}
+	assert.Nil(t, Validate(&ok))
+
+	bad := ok
+	bad.End = start
+	bad.Confirm = "secrets"
+	bad.Items = []bookingItem{{SKU: "a", Qty: 0}}
+	err := Validate(bad)
+	errs, isValidation := err.(ValidationErrors)
+	if !assert.True(t, isValidation, "%v", err) {
+		return
+	}
+	assert.Equal(t, []string{"End", "Start", "Confirm", "Password", "Address.City", "ID", "Items.Qty"}, errs.Fields())
+	assert.Equal(t, "End, Start: start must be before end", errs[0].Error())
+	assert.Equal(t, "Confirm, Password: validation failed: Password == Confirm", errs[1].Error())
+
+	// 引用了nil指针字段的规则不检查
+	noAddress := ok
+	noAddress.Address = nil
+	assert.Nil(t, Validate(noAddress))
+
+	// 注册时检查规则
+	assert.EqualError(t, RegisterStructRules(booking{}, StructRule{Rule: "Start < Finish"}),
+		`booking: invalid rule "Start < Finish": unknown field Finish`)
+	assert.EqualError(t, RegisterStructRules(booking{}, StructRule{Rule: "Items.Qty > 1"}),
+		`booking: invalid rule "Items.Qty > 1": `+
+			`InfixExpression((Items.Qty > 1)) unknow left type(ARRAY_INTEGER_OBJ)`)
+	assert.EqualError(t, RegisterStructRules(1), "cannot register rules for int, want struct")
+}
+
+func TestBindNested(t *testing.T) {
+	env := NewEnvironment()
+	assert.Nil(t, Bind(env, booking{
+		bookingBase: bookingBase{ID: 7},
+		Items:       []bookingItem{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 2}},
+	}))
+	for name, expect := range map[string]Object{
+		"ID":        &Integer{Value: 7},
+		"Items.SKU": NewStringArray("a", "b"),
+		"Items.Qty": NewIntegerArray(1, 2),
+	} {
+		obj, ok := env.Get(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expect, obj, name)
+	}
+	// nil指针被忽略
+	_, ok := env.Get("Address.City")
+	assert.False(t, ok)
+
+	program, err := Compile(`"b" in Items.SKU && ID == 7`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"ID", "Items.SKU"}, Variables(program))
+		assert.Equal(t, boolTrue, Eval(program, env))
+	}
+}
+
+// 绑定的类型和structSchema声明的类型一致，超出int64的无符号整数不绑定
+func TestBindNumbers(t *testing.T) {
+	type numbers struct {
+		Price  float64
+		Ratios []float32
+		Count  uint64
+		Huge   uint64
+	}
+	v := numbers{Price: 2, Ratios: []float32{1, 0.5}, Count: 3, Huge: math.MaxInt64 + 1}
+	env := NewEnvironment()
+	assert.Nil(t, Bind(env, v))
+	schema := structSchema(reflect.TypeOf(v))
+	for name, expect := range map[string]Object{
+		"Price":  &Float{Value: 2},
+		"Ratios": &Array{Elements: []Expression{&Float{Value: 1}, &Float{Value: 0.5}}, ElemType: FLOAT_OBJ},
+		"Count":  &Integer{Value: 3},
+	} {
+		obj, ok := env.Get(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expect, obj, name)
+		assert.Equal(t, schema[name], obj.ObjectType(), name)
+	}
+	_, ok := env.Get("Huge")
+	assert.False(t, ok)
+	_, err := ToObject(uint64(math.MaxUint64))
+	assert.EqualError(t, err, "unsupported number 18446744073709551615: overflows int64")
+
+	// encoding/json解析的数字转换为整数，2^63超出int64的范围，保持浮点数
+	for value, expect := range map[float64]Object{
+		3:        &Integer{Value: 3},
+		-1 << 63: &Integer{Value: math.MinInt64},
+		1 << 63:  &Float{Value: 1 << 63},
+		2.5:      &Float{Value: 2.5},
+	} {
+		obj, err := ToObject(value)
+		assert.Nil(t, err)
+		assert.Equal(t, expect, obj, "%v", value)
+	}
+	obj, err := ToObject([]interface{}{1.0, 2.5})
+	assert.Nil(t, err)
+	assert.Equal(t, "[1,2.5]", obj.(*Array).String())
+}
+
+type optionalItem struct {
+	SKU   string
+	Qty   *int
+	Owner *bookingAddress
+}
+
+func TestBindSliceAlignment(t *testing.T) {
+	qty := 2
+	env := NewEnvironment()
+	// 切片中的nil元素被忽略，nil字段使用零值，各个数组按下标对齐
+	assert.Nil(t, Bind(env, struct{ Items []*optionalItem }{Items: []*optionalItem{
+		{SKU: "a"},
+		nil,
+		{SKU: "b", Qty: &qty, Owner: &bookingAddress{City: "Beijing"}},
+	}}))
+	for name, expect := range map[string]Object{
+		"Items.SKU":        NewStringArray("a", "b"),
+		"Items.Qty":        NewIntegerArray(0, 2),
+		"Items.Owner.City": NewStringArray("", "Beijing"),
+	} {
+		obj, ok := env.Get(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expect, obj, name)
+	}
+}
@@ -0,0 +1,186 @@
+package conditions
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Visitor 遍历AST时对每个节点调用Visit
+// 如果返回的w不为nil，Walk使用w继续遍历该节点的子节点，最后调用w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk 深度优先遍历AST，同go/ast.Walk
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *Program:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *PrefixExpresion:
+		Walk(v, n.Right)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *CallExpression:
+		Walk(v, n.Function)
+		for _, a := range n.Arguments {
+			Walk(v, a)
+		}
+	case *Array:
+		for _, e := range n.Elements {
+			Walk(v, e)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect 深度优先遍历AST，对每个节点调用f
+// f返回true时继续遍历该节点的子节点，遍历完子节点后调用f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// RewriteFunc 返回用来替换node的节点，返回node本身表示不替换
+// 替换表达式节点时必须返回非nil的Expression
+type RewriteFunc func(node Node) Node
+
+// Rewrite 自底向上改写AST，同astutil.Apply的post阶段
+// 先改写子节点，再以改写后的节点调用f，返回改写后的根节点；原AST不会被修改
+// f返回nil或者将表达式替换为不是Expression的节点时返回error
+func Rewrite(node Node, f RewriteFunc) (Node, error) {
+	var err error
+	switch n := node.(type) {
+	case *Program:
+		cp := &Program{}
+		if n.Expression != nil {
+			if cp.Expression, err = rewriteExpression(n.Expression, f); err != nil {
+				return nil, err
+			}
+		}
+		node = cp
+	case *PrefixExpresion:
+		cp := &PrefixExpresion{Operator: n.Operator}
+		if cp.Right, err = rewriteExpression(n.Right, f); err != nil {
+			return nil, err
+		}
+		node = cp
+	case *InfixExpression:
+		cp := &InfixExpression{Operator: n.Operator}
+		if cp.Left, err = rewriteExpression(n.Left, f); err != nil {
+			return nil, err
+		}
+		if cp.Right, err = rewriteExpression(n.Right, f); err != nil {
+			return nil, err
+		}
+		node = cp
+	case *Array:
+		cp := &Array{Elements: make([]Expression, len(n.Elements)), ElemType: n.ElemType}
+		for i, e := range n.Elements {
+			if cp.Elements[i], err = rewriteExpression(e, f); err != nil {
+				return nil, err
+			}
+		}
+		if len(cp.Elements) != 0 {
+			cp.ElemType = commonType(cp.Elements)
+		}
+		node = cp
+	case *CallExpression:
+		cp := &CallExpression{Arguments: make([]Expression, len(n.Arguments))}
+		if cp.Function, err = rewriteExpression(n.Function, f); err != nil {
+			return nil, err
+		}
+		for i, a := range n.Arguments {
+			if cp.Arguments[i], err = rewriteExpression(a, f); err != nil {
+				return nil, err
+			}
+		}
+		node = cp
+	}
+	ret := f(node)
+	if ret == nil {
+		return nil, fmt.Errorf("rewrite %T: got nil", node)
+	}
+	return ret, nil
+}
+
+// rewriteExpression 改写子表达式，结果必须是Expression
+func rewriteExpression(exp Expression, f RewriteFunc) (Expression, error) {
+	node, err := Rewrite(exp, f)
+	if err != nil {
+		return nil, err
+	}
+	ret, ok := node.(Expression)
+	if !ok {
+		return nil, fmt.Errorf("rewrite %s: got %T, want Expression", exp.String(), node)
+	}
+	return ret, nil
+}
+
+// Variables 返回表达式中引用的所有变量名，已排序且去重，不包括量词的循环变量
+func Variables(program *Program) []string {
+	names := map[string]struct{}{}
+	collectVariables(program, names)
+	return sortedNames(names)
+}
+
+func collectVariables(node Node, names map[string]struct{}) {
+	Inspect(node, func(node Node) bool {
+		switch n := node.(type) {
+		case *CallExpression:
+			// 循环变量只在谓词中可见
+			if variable, ok := loopVariable(n); ok {
+				collectVariables(n.Arguments[0], names)
+				inner := map[string]struct{}{}
+				collectVariables(n.Arguments[2], inner)
+				delete(inner, variable)
+				for name := range inner {
+					names[name] = struct{}{}
+				}
+				return false
+			}
+			// 函数名不是变量，只遍历参数
+			for _, a := range n.Arguments {
+				collectVariables(a, names)
+			}
+			return false
+		case *Identifier:
+			names[n.Value] = struct{}{}
+		}
+		return true
+	})
+}
+
+// Functions 返回表达式中调用的所有函数名，已排序且去重
+func Functions(program *Program) []string {
+	names := map[string]struct{}{}
+	Inspect(program, func(node Node) bool {
+		if call, ok := node.(*CallExpression); ok {
+			names[call.Function.String()] = struct{}{}
+		}
+		return true
+	})
+	return sortedNames(names)
+}
+
+func sortedNames(names map[string]struct{}) []string {
+	ret := make([]string, 0, len(names))
+	for name := range names {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,104 @@
+package conditions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariablesAndFunctions(t *testing.T) {
+	input := `(len(abc) > 1 && X == "123") || !(Y in [1, 2, 3]) || len(X) > Y`
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+
+	assert.Equal(t, []string{"X", "Y", "abc"}, Variables(program))
+	assert.Equal(t, []string{"len"}, Functions(program))
+}
+
+type countVisitor map[string]int
+
+func (c countVisitor) Visit(node Node) Visitor {
+	switch node.(type) {
+	case *Identifier:
+		c["ident"]++
+	case *InfixExpression:
+		c["infix"]++
+	}
+	return c
+}
+
+func TestWalkAndRewrite(t *testing.T) {
+	p := NewParser(NewLexer(`a > 1 && len(b) == c`))
+	program := p.ParseProgram()
+
+	counts := countVisitor{}
+	Walk(counts, program)
+	assert.Equal(t, countVisitor{"ident": 4, "infix": 3}, counts)
+
+	// 将标识符改写为带前缀的列名
+	rewritten, err := Rewrite(program, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value != "len" {
+			return &Identifier{Value: "t_" + ident.Value}
+		}
+		return node
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "((t_a > 1) && (len(t_b) == t_c))", rewritten.String())
+	assert.Equal(t, "((a > 1) && (len(b) == c))", program.String())
+
+	// 表达式只能替换为Expression
+	_, err = Rewrite(program, func(node Node) Node {
+		if _, ok := node.(*Integer); ok {
+			return nil
+		}
+		return node
+	})
+	assert.EqualError(t, err, "rewrite *conditions.Integer: got nil")
+	_, err = Rewrite(program, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "c" {
+			return &Program{Expression: ident}
+		}
+		return node
+	})
+	assert.EqualError(t, err, "rewrite c: got *conditions.Program, want Expression")
+}
+
+func TestInspectPostOrder(t *testing.T) {
+	program := NewParser(NewLexer(`!a && len(b)`)).ParseProgram()
+	var events []string
+	Inspect(program, func(node Node) bool {
+		if node == nil {
+			events = append(events, "end")
+			return true
+		}
+		events = append(events, fmt.Sprintf("%T", node))
+		// 不遍历函数调用的子节点，也不会有对应的f(nil)
+		_, isCall := node.(*CallExpression)
+		return !isCall
+	})
+	assert.Equal(t, []string{
+		"*conditions.Program",
+		"*conditions.InfixExpression",
+		"*conditions.PrefixExpresion",
+		"*conditions.Identifier", "end",
+		"end",
+		"*conditions.CallExpression",
+		"end",
+		"end",
+	}, events)
+}
+
+func TestVariablesNestedQuantifiers(t *testing.T) {
+	for input, expect := range map[string][]string{
+		`all(orders, o, any(o_items, i, i > o && i < limit))`:   {"limit", "o_items", "orders"},
+		`any(xs, x, all(ys, y, y > x)) && x > 0`:                {"x", "xs", "ys"},
+		`count(xs, x, any(xs, x, x == 1)) > 1`:                  {"xs"},
+		`none(xs, x, any(ys, y, y == x) || all(zs, x, x == y))`: {"xs", "y", "ys", "zs"},
+	} {
+		program, err := Compile(input)
+		if assert.Nil(t, err, input) {
+			assert.Equal(t, expect, Variables(program), input)
+		}
+	}
+}